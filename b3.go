@@ -0,0 +1,166 @@
+package omnipulse
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	b3SingleHeader       = "b3"
+	b3TraceIDHeader      = "X-B3-TraceId"
+	b3SpanIDHeader       = "X-B3-SpanId"
+	b3ParentSpanIDHeader = "X-B3-ParentSpanId"
+	b3SampledHeader      = "X-B3-Sampled"
+	b3FlagsHeader        = "X-B3-Flags"
+	jaegerHeader         = "uber-trace-id"
+)
+
+// B3Propagator implements Zipkin B3 propagation. Extract accepts both the single b3 header
+// and the X-B3-* headers; Inject writes the format selected by SingleHeader.
+//
+// 64-bit trace IDs are left-padded with zeros to the 128-bit form used by the SDK, so a
+// trace keeps one ID across B3, W3C and OmniPulse hops. Requests without a sampling
+// decision are treated as sampled.
+type B3Propagator struct {
+	// SingleHeader injects the compact b3 header instead of the X-B3-* headers
+	SingleHeader bool
+}
+
+func (B3Propagator) Extract(carrier Carrier) SpanContext {
+	if v := carrier.Get(b3SingleHeader); v != "" {
+		return extractB3Single(v)
+	}
+
+	traceID, spanID, ok := b3IDs(carrier.Get(b3TraceIDHeader), carrier.Get(b3SpanIDHeader))
+	if !ok {
+		return SpanContext{}
+	}
+
+	sampled := true
+	switch strings.ToLower(carrier.Get(b3SampledHeader)) {
+	case "0", "false":
+		sampled = false
+	}
+	if carrier.Get(b3FlagsHeader) == "1" {
+		sampled = true
+	}
+
+	return SpanContext{TraceID: traceID, SpanID: spanID, Sampled: sampled, Remote: true}
+}
+
+// extractB3Single parses {TraceId}-{SpanId}[-{SamplingState}[-{ParentSpanId}]]. A header
+// carrying only a sampling state has no IDs to continue and is ignored.
+func extractB3Single(v string) SpanContext {
+	parts := strings.Split(strings.TrimSpace(v), "-")
+	if len(parts) < 2 || len(parts) > 4 {
+		return SpanContext{}
+	}
+	traceID, spanID, ok := b3IDs(parts[0], parts[1])
+	if !ok {
+		return SpanContext{}
+	}
+
+	sampled := true
+	if len(parts) > 2 {
+		switch parts[2] {
+		case "0":
+			sampled = false
+		case "1", "d":
+		default:
+			return SpanContext{}
+		}
+	}
+
+	return SpanContext{TraceID: traceID, SpanID: spanID, Sampled: sampled, Remote: true}
+}
+
+// b3IDs validates a 64- or 128-bit B3 trace ID and a 64-bit span ID, padding the trace ID
+// to 128 bits
+func b3IDs(traceID, spanID string) (string, string, bool) {
+	if len(traceID) != 16 && len(traceID) != 32 || len(spanID) != 16 {
+		return "", "", false
+	}
+	traceID, ok := normalizeID(traceID, 32)
+	if !ok {
+		return "", "", false
+	}
+	spanID, ok = normalizeID(spanID, 16)
+	return traceID, spanID, ok
+}
+
+func (p B3Propagator) Inject(sc SpanContext, carrier Carrier) {
+	if !isHexID(sc.TraceID, 32) || !isHexID(sc.SpanID, 16) {
+		return
+	}
+	sampled := "0"
+	if sc.Sampled {
+		sampled = "1"
+	}
+	if p.SingleHeader {
+		carrier.Set(b3SingleHeader, fmt.Sprintf("%s-%s-%s", sc.TraceID, sc.SpanID, sampled))
+		return
+	}
+	carrier.Set(b3TraceIDHeader, sc.TraceID)
+	carrier.Set(b3SpanIDHeader, sc.SpanID)
+	carrier.Set(b3SampledHeader, sampled)
+}
+
+func (p B3Propagator) Fields() []string {
+	if p.SingleHeader {
+		return []string{b3SingleHeader}
+	}
+	return []string{b3TraceIDHeader, b3SpanIDHeader, b3ParentSpanIDHeader, b3SampledHeader, b3FlagsHeader}
+}
+
+// JaegerPropagator implements the Jaeger uber-trace-id header
+// ({trace-id}:{span-id}:{parent-span-id}:{flags}). Jaeger omits leading zeros, so IDs are
+// left-padded to 32 and 16 hex digits on extract.
+type JaegerPropagator struct{}
+
+func (JaegerPropagator) Extract(carrier Carrier) SpanContext {
+	v := carrier.Get(jaegerHeader)
+	if v == "" {
+		return SpanContext{}
+	}
+	// Some clients URL-encode the colons
+	if unescaped, err := url.QueryUnescape(v); err == nil {
+		v = unescaped
+	}
+
+	parts := strings.Split(strings.TrimSpace(v), ":")
+	if len(parts) != 4 {
+		return SpanContext{}
+	}
+	traceID, ok := normalizeID(parts[0], 32)
+	if !ok {
+		return SpanContext{}
+	}
+	spanID, ok := normalizeID(parts[1], 16)
+	if !ok {
+		return SpanContext{}
+	}
+	flags, err := strconv.ParseUint(parts[3], 16, 8)
+	if err != nil {
+		return SpanContext{}
+	}
+
+	// Bit 1 is sampled, bit 2 is debug (which implies sampled)
+	return SpanContext{TraceID: traceID, SpanID: spanID, Sampled: flags&0x3 != 0, Remote: true}
+}
+
+func (JaegerPropagator) Inject(sc SpanContext, carrier Carrier) {
+	if !isHexID(sc.TraceID, 32) || !isHexID(sc.SpanID, 16) {
+		return
+	}
+	flags := 0
+	if sc.Sampled {
+		flags = 1
+	}
+	carrier.Set(jaegerHeader, fmt.Sprintf("%s:%s:0:%d", sc.TraceID, sc.SpanID, flags))
+}
+
+func (JaegerPropagator) Fields() []string {
+	return []string{jaegerHeader}
+}
@@ -0,0 +1,150 @@
+package omnipulse
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// OverflowPolicy decides what happens to new items when a signal's buffer is full
+type OverflowPolicy int
+
+const (
+	// OverflowDropNewest discards the item being added
+	OverflowDropNewest OverflowPolicy = iota
+	// OverflowDropOldest discards the oldest buffered items to make room
+	OverflowDropOldest
+	// OverflowBlock waits up to Config.BlockTimeout for a flush to make room, then drops the item
+	OverflowBlock
+)
+
+func (p OverflowPolicy) String() string {
+	switch p {
+	case OverflowDropNewest:
+		return "drop_newest"
+	case OverflowDropOldest:
+		return "drop_oldest"
+	case OverflowBlock:
+		return "block"
+	}
+	return fmt.Sprintf("overflow_policy(%d)", int(p))
+}
+
+const numSignals = 4
+
+// Dropped returns the number of items of the given signal discarded because its buffer was full
+func (c *Client) Dropped(sig Signal) uint64 {
+	if sig < 0 || sig >= numSignals {
+		return 0
+	}
+	return c.dropped[sig].Load()
+}
+
+// triggerFlush asks the flush worker to flush soon; triggers coalesce while one is pending
+func (c *Client) triggerFlush() {
+	select {
+	case c.flushCh <- struct{}{}:
+	default:
+	}
+}
+
+// bufferFull reports whether adding an item of size n would exceed the caps for sig.
+// c.bufferMu must be held.
+func (c *Client) bufferFull(sig Signal, items, n int) bool {
+	return items >= c.config.MaxBufferItems || c.bufferBytes[sig]+n > c.config.MaxBufferBytes
+}
+
+// enqueue appends item to buf, applying policy when the buffer is full, and reports
+// whether the buffer has reached BatchSize
+func enqueue[T any](c *Client, sig Signal, buf *[]T, item T, size func(T) int, policy OverflowPolicy) bool {
+	n := size(item)
+	var deadline time.Time
+
+	c.bufferMu.Lock()
+	for c.bufferFull(sig, len(*buf), n) {
+		switch {
+		case policy == OverflowDropOldest && len(*buf) > 0:
+			var zero T
+			c.bufferBytes[sig] -= size((*buf)[0])
+			(*buf)[0] = zero
+			*buf = (*buf)[1:]
+			c.dropped[sig].Add(1)
+			c.diag(slog.LevelDebug, "buffer full, dropping oldest item", "signal", sig.String())
+			continue
+
+		case policy == OverflowBlock && len(*buf) > 0:
+			if deadline.IsZero() {
+				deadline = time.Now().Add(c.config.BlockTimeout)
+			}
+			wait := time.Until(deadline)
+			if wait > 0 {
+				space := c.spaceCh
+				c.bufferMu.Unlock()
+				c.triggerFlush()
+				timer := time.NewTimer(wait)
+				select {
+				case <-space:
+				case <-timer.C:
+				}
+				timer.Stop()
+				c.bufferMu.Lock()
+				continue
+			}
+		}
+
+		c.bufferMu.Unlock()
+		c.dropped[sig].Add(1)
+		c.diag(slog.LevelDebug, "buffer full, dropping item", "signal", sig.String(), "policy", policy.String())
+		return false
+	}
+
+	*buf = append(*buf, item)
+	c.bufferBytes[sig] += n
+	c.stats.enqueued[sig].Add(1)
+	shouldFlush := len(*buf) >= c.config.BatchSize
+	c.bufferMu.Unlock()
+
+	return shouldFlush
+}
+
+// Approximate in-memory sizes of buffered items, used for MaxBufferBytes accounting
+
+func logEntrySize(e LogEntry) int {
+	return 128 + len(e.Message) + len(e.ServiceName) + len(e.TraceID) + len(e.SpanID) + len(e.Host) + tagsSize(e.Tags)
+}
+
+func spanDataSize(s SpanData) int {
+	n := 256 + len(s.Name) + len(s.ServiceName) + len(s.StatusMessage) + tagsSize(s.Attributes)
+	for _, e := range s.Events {
+		n += 64 + len(e.Name) + tagsSize(e.Attributes)
+	}
+	for _, l := range s.Links {
+		n += 64 + tagsSize(l.Attributes)
+	}
+	return n
+}
+
+func metricDataSize(m MetricData) int {
+	n := 96 + len(m.Name) + len(m.ServiceName) + tagsSize(m.Dimensions)
+	for k, v := range m.Tags {
+		n += len(k) + len(v)
+	}
+	return n
+}
+
+func jobDataSize(j JobData) int {
+	return 96 + len(j.JobName) + len(j.Queue) + len(j.Status) + len(j.Error) + len(j.Ts)
+}
+
+func tagsSize(tags map[string]interface{}) int {
+	n := 0
+	for k, v := range tags {
+		n += len(k)
+		if s, ok := v.(string); ok {
+			n += len(s)
+		} else {
+			n += 16
+		}
+	}
+	return n
+}
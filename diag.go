@@ -0,0 +1,35 @@
+package omnipulse
+
+import (
+	"context"
+	"log/slog"
+	"os"
+)
+
+// newDiagnosticLogger returns the logger for the SDK's own diagnostics
+func newDiagnosticLogger(cfg Config) *slog.Logger {
+	if cfg.DiagnosticLogger != nil {
+		return cfg.DiagnosticLogger.With("component", "omnipulse")
+	}
+	if cfg.Debug {
+		return slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})).With("component", "omnipulse")
+	}
+	return slog.New(slog.DiscardHandler)
+}
+
+// diag records an SDK diagnostic event
+func (c *Client) diag(level slog.Level, msg string, args ...any) {
+	if c.diagLogger == nil {
+		return
+	}
+	c.diagLogger.Log(context.Background(), level, msg, args...)
+}
+
+// reportError records an error the SDK cannot return to the caller and passes it to
+// Config.ErrorHandler
+func (c *Client) reportError(err error, msg string, args ...any) {
+	c.diag(slog.LevelError, msg, append(args, "error", err)...)
+	if c.config.ErrorHandler != nil {
+		c.config.ErrorHandler(err)
+	}
+}
@@ -0,0 +1,142 @@
+package omnipulse
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+
+	"github.com/masbenx/omnipulse-go/semconv"
+)
+
+// maxErrorChain bounds how many wrapped errors RecordError lists
+const maxErrorChain = 16
+
+// ErrorOption configures RecordError
+type ErrorOption func(*errorConfig)
+
+type errorConfig struct {
+	stack      bool
+	attributes map[string]interface{}
+}
+
+// WithStackTrace captures the caller's stack trace in the exception event
+func WithStackTrace() ErrorOption {
+	return func(c *errorConfig) {
+		c.stack = true
+	}
+}
+
+// WithErrorAttributes adds attributes to the exception event
+func WithErrorAttributes(attrs map[string]interface{}) ErrorOption {
+	return func(c *errorConfig) {
+		c.attributes = attrs
+	}
+}
+
+// RecordError adds an exception event describing err and sets the span status to error.
+// The event holds the error's type, message and the types and messages of the errors it
+// wraps. A nil err is ignored.
+func (s *Span) RecordError(err error, opts ...ErrorOption) {
+	if err == nil {
+		return
+	}
+	s.mu.Lock()
+	ended := s.endedLocked("RecordError")
+	s.mu.Unlock()
+	if ended {
+		return
+	}
+	var cfg errorConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	attrs := map[string]interface{}{
+		semconv.ExceptionType:    errorType(err),
+		semconv.ExceptionMessage: err.Error(),
+	}
+	if chain := errorChain(err); len(chain) > 0 {
+		attrs[semconv.ExceptionChain] = chain
+	}
+	if cfg.stack {
+		attrs[semconv.ExceptionStacktrace] = captureStack(2)
+	}
+	for k, v := range cfg.attributes {
+		attrs[k] = v
+	}
+
+	s.AddEvent(semconv.ExceptionEventName, attrs)
+	s.SetStatus(SpanStatusError, err.Error())
+	s.SetAttribute(semconv.Error, true)
+}
+
+// PanicError is the error recorded for a recovered panic
+type PanicError struct {
+	Value interface{}
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Unwrap returns the panic value if it is an error
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// errorType names the type of err, or of the panic value for a PanicError
+func errorType(err error) string {
+	if p, ok := err.(*PanicError); ok {
+		return fmt.Sprintf("%T", p.Value)
+	}
+	return fmt.Sprintf("%T", err)
+}
+
+// errorChain describes the errors wrapped by err, following errors.Unwrap and the
+// Unwrap() []error form used by errors.Join
+func errorChain(err error) []string {
+	var chain []string
+	queue := wrapped(err)
+	for len(queue) > 0 && len(chain) < maxErrorChain {
+		e := queue[0]
+		queue = append(queue[1:], wrapped(e)...)
+		chain = append(chain, fmt.Sprintf("%T: %s", e, e.Error()))
+	}
+	return chain
+}
+
+func wrapped(err error) []error {
+	switch e := err.(type) {
+	case interface{ Unwrap() error }:
+		if inner := e.Unwrap(); inner != nil {
+			return []error{inner}
+		}
+	case interface{ Unwrap() []error }:
+		var errs []error
+		for _, inner := range e.Unwrap() {
+			if inner != nil {
+				errs = append(errs, inner)
+			}
+		}
+		return errs
+	}
+	return nil
+}
+
+// captureStack formats the stack above skip frames in the style of runtime/debug.Stack
+func captureStack(skip int) string {
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(skip+1, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	var b strings.Builder
+	for {
+		frame, more := frames.Next()
+		fmt.Fprintf(&b, "%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+		if !more {
+			break
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,291 @@
+package omnipulse
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// Profile is a collected runtime profile ready for export
+type Profile struct {
+	// Data is the gzip-compressed pprof protobuf
+	Data []byte
+	// InstanceHash identifies the process the profile was taken from
+	InstanceHash string
+	// Type is the profile type, e.g. "cpu"
+	Type string
+	// DurationSeconds is the profiling window
+	DurationSeconds int
+}
+
+// Exporter delivers batches of telemetry to a backend.
+// Implementations should return a *SendError for failures that may succeed on retry;
+// the client retries and spools those according to Config.Retry and Config.SpoolDir.
+// Exporters that send a batch in several requests should wrap the error in a
+// *PartialExportError once some of them succeeded, so that only the rest is retried.
+type Exporter interface {
+	ExportLogs(ctx context.Context, logs []LogEntry) error
+	ExportSpans(ctx context.Context, spans []SpanData) error
+	ExportMetrics(ctx context.Context, metrics []MetricData) error
+	ExportJobs(ctx context.Context, jobs []JobData) error
+	ExportProfile(ctx context.Context, profile Profile) error
+	// Shutdown releases resources; the exporter is not used afterwards
+	Shutdown(ctx context.Context) error
+}
+
+// PartialExportError reports a batch that was only partly delivered. The first
+// Delivered+Rejected items were handled: Rejected of them were dropped because they could
+// not be encoded or were refused on their own, and the rest reached the backend. The
+// client retries or spools only the items after them.
+type PartialExportError struct {
+	// Delivered is the number of leading items that reached the backend
+	Delivered int
+	// Rejected is the number of leading items that were dropped and will never be delivered
+	Rejected int
+	// Err is the error that stopped the export, or that rejected the last dropped item
+	Err error
+}
+
+func (e *PartialExportError) Error() string {
+	if e.Rejected > 0 {
+		return fmt.Sprintf("delivered %d items and rejected %d: %v", e.Delivered, e.Rejected, e.Err)
+	}
+	return fmt.Sprintf("delivered %d items before failing: %v", e.Delivered, e.Err)
+}
+
+func (e *PartialExportError) Unwrap() error {
+	return e.Err
+}
+
+// partialExport wraps err in a *PartialExportError when some items were handled
+func partialExport(delivered, rejected int, err error) error {
+	if delivered == 0 && rejected == 0 {
+		return err
+	}
+	return &PartialExportError{Delivered: delivered, Rejected: rejected, Err: err}
+}
+
+// HTTPExporter sends telemetry to the OmniPulse ingest API as gzip-compressed JSON
+type HTTPExporter struct {
+	apiURL      string
+	ingestKey   string
+	environment string
+	maxPayload  int
+	httpClient  *http.Client
+}
+
+// NewHTTPExporter creates an exporter for the OmniPulse ingest API from cfg's
+// APIUrl, IngestKey, Environment, Timeout and MaxPayloadBytes
+func NewHTTPExporter(cfg Config) *HTTPExporter {
+	maxPayload := cfg.MaxPayloadBytes
+	if maxPayload <= 0 {
+		maxPayload = defaultMaxPayloadBytes
+	}
+	environment := cfg.Environment
+	if environment == "" {
+		environment = defaultEnvironment
+	}
+	return &HTTPExporter{
+		apiURL:      cfg.APIUrl,
+		ingestKey:   cfg.IngestKey,
+		environment: environment,
+		maxPayload:  maxPayload,
+		httpClient: &http.Client{
+			Timeout: cfg.Timeout,
+		},
+	}
+}
+
+// ExportLogs sends logs to /api/ingest/app-logs
+func (e *HTTPExporter) ExportLogs(ctx context.Context, logs []LogEntry) error {
+	return sendItems(ctx, e, "/api/ingest/app-logs", "entries", logs, truncateLogEntry)
+}
+
+// ExportSpans sends spans to /api/ingest/app-traces
+func (e *HTTPExporter) ExportSpans(ctx context.Context, spans []SpanData) error {
+	return sendItems(ctx, e, "/api/ingest/app-traces", "spans", spans, truncateSpanData)
+}
+
+// ExportMetrics sends metrics to /api/ingest/app-metrics
+func (e *HTTPExporter) ExportMetrics(ctx context.Context, metrics []MetricData) error {
+	return sendItems(ctx, e, "/api/ingest/app-metrics", "metrics", metrics, truncateMetricData)
+}
+
+// ExportJobs sends each job to /api/ingest/app-job, stopping at the first failure. Jobs
+// that cannot be encoded or that the backend rejects as too large are dropped.
+func (e *HTTPExporter) ExportJobs(ctx context.Context, jobs []JobData) error {
+	var rej rejections
+	for i, job := range jobs {
+		data, err := encodeItem(job, e.maxPayload, truncateJobData)
+		if err != nil {
+			rej.add(i, fmt.Errorf("failed to marshal payload: %w", err))
+			continue
+		}
+		err = e.sendRaw(ctx, "/api/ingest/app-job", data)
+		var se *SendError
+		if errors.As(err, &se) && se.StatusCode == http.StatusRequestEntityTooLarge {
+			rej.add(i, err)
+			continue
+		}
+		if err != nil {
+			return partialExport(i-len(rej.indexes), len(rej.indexes), err)
+		}
+	}
+	if n := len(rej.indexes); n > 0 {
+		return &PartialExportError{Delivered: len(jobs) - n, Rejected: n, Err: rej.err}
+	}
+	return nil
+}
+
+// ExportProfile uploads a profile to /api/ingest/app-profiles
+func (e *HTTPExporter) ExportProfile(ctx context.Context, profile Profile) error {
+	if len(profile.Data) == 0 {
+		return nil
+	}
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+
+	part, err := writer.CreateFormFile("profile", profile.Type+".pb.gz")
+	if err != nil {
+		return fmt.Errorf("failed to create profile form: %w", err)
+	}
+	if _, err := part.Write(profile.Data); err != nil {
+		return fmt.Errorf("failed to write profile form: %w", err)
+	}
+
+	writer.WriteField("instance_hash", profile.InstanceHash)
+	writer.WriteField("env", e.environment)
+	writer.WriteField("profile_type", profile.Type)
+	writer.WriteField("duration_seconds", strconv.Itoa(profile.DurationSeconds))
+	writer.Close()
+
+	return e.post(ctx, "/api/ingest/app-profiles", writer.FormDataContentType(), "", body.Bytes())
+}
+
+// Shutdown closes idle connections
+func (e *HTTPExporter) Shutdown(ctx context.Context) error {
+	e.httpClient.CloseIdleConnections()
+	return nil
+}
+
+func (e *HTTPExporter) sendRaw(ctx context.Context, endpoint string, data []byte) error {
+	// Compress with gzip
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(data); err != nil {
+		return fmt.Errorf("failed to compress payload: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to close gzip writer: %w", err)
+	}
+
+	return e.post(ctx, endpoint, "application/json", "gzip", buf.Bytes())
+}
+
+func (e *HTTPExporter) post(ctx context.Context, endpoint, contentType, contentEncoding string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", e.apiURL+endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", contentType)
+	if contentEncoding != "" {
+		req.Header.Set("Content-Encoding", contentEncoding)
+	}
+	req.Header.Set("X-Ingest-Key", e.ingestKey)
+	req.Header.Set("User-Agent", fmt.Sprintf("omnipulse-go-sdk/%s", Version))
+
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return &SendError{Err: err}
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode >= 400 {
+		return newStatusError(resp)
+	}
+
+	recordBytesSent(ctx, len(body))
+	return nil
+}
+
+// multiExporter fans every batch out to several exporters
+type multiExporter struct {
+	exporters []Exporter
+}
+
+// MultiExporter returns an Exporter that sends every batch to all of the given exporters
+// concurrently. It fails if any of them fails, so a retried batch may be delivered more
+// than once to the exporters that succeeded.
+func MultiExporter(exporters ...Exporter) Exporter {
+	return &multiExporter{exporters: exporters}
+}
+
+func (m *multiExporter) ExportLogs(ctx context.Context, logs []LogEntry) error {
+	return m.eachBatch(len(logs), func(e Exporter) error { return e.ExportLogs(ctx, logs) })
+}
+
+func (m *multiExporter) ExportSpans(ctx context.Context, spans []SpanData) error {
+	return m.eachBatch(len(spans), func(e Exporter) error { return e.ExportSpans(ctx, spans) })
+}
+
+func (m *multiExporter) ExportMetrics(ctx context.Context, metrics []MetricData) error {
+	return m.eachBatch(len(metrics), func(e Exporter) error { return e.ExportMetrics(ctx, metrics) })
+}
+
+func (m *multiExporter) ExportJobs(ctx context.Context, jobs []JobData) error {
+	return m.eachBatch(len(jobs), func(e Exporter) error { return e.ExportJobs(ctx, jobs) })
+}
+
+func (m *multiExporter) ExportProfile(ctx context.Context, profile Profile) error {
+	return m.each(func(e Exporter) error { return e.ExportProfile(ctx, profile) })
+}
+
+func (m *multiExporter) Shutdown(ctx context.Context) error {
+	return m.each(func(e Exporter) error { return e.Shutdown(ctx) })
+}
+
+// eachBatch is like each for a batch of n items. Partial failures are merged into one
+// *PartialExportError covering the items every exporter handled, so that the inner ones
+// cannot make the client skip items another exporter still needs.
+func (m *multiExporter) eachBatch(n int, fn func(Exporter) error) error {
+	err := m.each(fn)
+	var pe *PartialExportError
+	if !errors.As(err, &pe) {
+		return err
+	}
+	handled, rejected := n, 0
+	for _, err := range err.(interface{ Unwrap() []error }).Unwrap() {
+		if errors.As(err, &pe) {
+			handled = min(handled, pe.Delivered+pe.Rejected)
+			rejected = max(rejected, pe.Rejected)
+		} else {
+			handled = 0
+		}
+	}
+	rejected = min(rejected, handled)
+	return &PartialExportError{Delivered: handled - rejected, Rejected: rejected, Err: err}
+}
+
+func (m *multiExporter) each(fn func(Exporter) error) error {
+	errs := make([]error, len(m.exporters))
+	var wg sync.WaitGroup
+	for i, e := range m.exporters {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = fn(e)
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
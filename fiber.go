@@ -2,75 +2,125 @@ package omnipulse
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/masbenx/omnipulse-go/semconv"
 )
 
 // FiberMiddleware returns a Fiber middleware for automatic instrumentation
 func FiberMiddleware(client *Client) fiber.Handler {
 	return func(c *fiber.Ctx) error {
+		// Fiber's strings point into pooled request buffers, so copy any kept past the handler
+		path := strings.Clone(c.Path())
+
 		// Skip health check endpoints
-		path := c.Path()
 		if path == "/health" || path == "/healthz" || path == "/ready" || path == "/readyz" {
 			return c.Next()
 		}
 
+		// Extract trace context from the incoming headers
+		remote := client.Extract(fiberCarrier{c})
+
 		// Start span
-		span := client.Tracer().StartSpan(
+		ctx, span := client.Tracer().Start(c.UserContext(),
 			fmt.Sprintf("%s %s", c.Method(), path),
 			WithAttributes(map[string]interface{}{
-				"http.method":      c.Method(),
-				"http.url":         c.OriginalURL(),
-				"http.route":       c.Route().Path,
-				"http.user_agent":  c.Get("User-Agent"),
-				"http.remote_addr": c.IP(),
+				semconv.HTTPMethod:     strings.Clone(c.Method()),
+				semconv.HTTPURL:        strings.Clone(c.OriginalURL()),
+				semconv.HTTPTarget:     path,
+				semconv.HTTPUserAgent:  strings.Clone(c.Get("User-Agent")),
+				semconv.HTTPRemoteAddr: strings.Clone(c.IP()),
 			}),
+			WithRemoteParent(remote),
+			WithSpanKind(SpanKindServer),
 		)
 
-		// Store span in context for downstream logging
+		// Store span in context for downstream logging and SpanFromContext(c.UserContext())
 		c.Locals("omnipulse_span", span)
 		c.Locals("omnipulse_trace_id", span.TraceID)
+		c.SetUserContext(ctx)
+
+		// Add trace headers to response
+		c.Set(omnipulseTraceHeader, span.TraceID)
 
 		start := time.Now()
 
+		// Record panics on the span, then let them propagate to fiber's recover middleware
+		defer func() {
+			if p := recover(); p != nil {
+				span.RecordError(&PanicError{Value: p}, WithStackTrace())
+				span.SetAttribute(semconv.HTTPRoute, c.Route().Path)
+				span.SetAttribute(semconv.HTTPStatusCode, fiber.StatusInternalServerError)
+				span.End()
+				recordFiberMetrics(client, c, fiber.StatusInternalServerError, time.Since(start))
+				panic(p)
+			}
+		}()
+
 		// Execute handler
 		err := c.Next()
 
 		duration := time.Since(start)
 		statusCode := c.Response().StatusCode()
 
+		// The matched route is only known once the handler chain has run
+		span.SetAttribute(semconv.HTTPRoute, c.Route().Path)
+
 		// Set response attributes
-		span.SetAttribute("http.status_code", statusCode)
-		span.SetAttribute("http.response_size", len(c.Response().Body()))
+		span.SetAttribute(semconv.HTTPStatusCode, statusCode)
+		span.SetAttribute(semconv.HTTPResponseSize, len(c.Response().Body()))
 
 		if err != nil {
-			span.SetStatus(SpanStatusError, err.Error())
-			span.SetAttribute("error", true)
-			span.SetAttribute("error.message", err.Error())
+			span.RecordError(err)
+			span.SetAttribute(semconv.ErrorMessage, err.Error())
 		} else if statusCode >= 400 {
 			span.SetStatus(SpanStatusError, fmt.Sprintf("HTTP %d", statusCode))
-			span.SetAttribute("error", true)
+			span.SetAttribute(semconv.Error, true)
 		}
 
 		span.End()
-
-		// Record metrics
-		client.Metrics().RecordDuration("http.request.duration", duration, map[string]string{
-			"method":      c.Method(),
-			"route":       c.Route().Path,
-			"status_code": fmt.Sprintf("%d", statusCode),
-		})
-		client.Metrics().Increment("http.request.count", map[string]string{
-			"method":      c.Method(),
-			"route":       c.Route().Path,
-			"status_code": fmt.Sprintf("%d", statusCode),
-		})
+		recordFiberMetrics(client, c, statusCode, duration)
 
 		return err
 	}
 }
 
+// recordFiberMetrics records the request duration and count metrics for a handled request
+func recordFiberMetrics(client *Client, c *fiber.Ctx, statusCode int, duration time.Duration) {
+	tags := map[string]string{
+		"method":      strings.Clone(c.Method()),
+		"route":       c.Route().Path,
+		"status_code": fmt.Sprintf("%d", statusCode),
+	}
+	client.Metrics().RecordDuration("http.request.duration", duration, tags)
+	client.Metrics().Increment("http.request.count", tags)
+}
+
+// fiberCarrier adapts the request headers of a Fiber context to Carrier
+type fiberCarrier struct {
+	c *fiber.Ctx
+}
+
+// Get copies the header value, which would otherwise be reused by the next request
+func (f fiberCarrier) Get(key string) string {
+	return strings.Clone(f.c.Get(key))
+}
+
+func (f fiberCarrier) Set(key, value string) {
+	f.c.Request().Header.Set(key, value)
+}
+
+func (f fiberCarrier) Keys() []string {
+	headers := f.c.GetReqHeaders()
+	keys := make([]string, 0, len(headers))
+	for k := range headers {
+		keys = append(keys, strings.Clone(k))
+	}
+	return keys
+}
+
 // GetSpanFromFiber retrieves the current span from Fiber context
 func GetSpanFromFiber(c *fiber.Ctx) *Span {
 	span, ok := c.Locals("omnipulse_span").(*Span)
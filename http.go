@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"net/http"
 	"time"
+
+	"github.com/masbenx/omnipulse-go/semconv"
 )
 
 // responseWriter wraps http.ResponseWriter to capture status code
@@ -40,35 +42,27 @@ func HTTPMiddleware(client *Client) func(http.Handler) http.Handler {
 				return
 			}
 
-			// Extract or generate trace context
-			traceID := r.Header.Get("X-OmniPulse-Trace-ID")
-			parentSpanID := r.Header.Get("X-OmniPulse-Span-ID")
+			// Extract trace context from the incoming headers
+			remote := client.Extract(HeaderCarrier(r.Header))
 
 			opts := []SpanOption{
 				WithAttributes(map[string]interface{}{
-					"http.method":      r.Method,
-					"http.url":         r.URL.String(),
-					"http.host":        r.Host,
-					"http.user_agent":  r.UserAgent(),
-					"http.remote_addr": r.RemoteAddr,
+					semconv.HTTPMethod:     r.Method,
+					semconv.HTTPURL:        r.URL.String(),
+					semconv.HTTPTarget:     path,
+					semconv.HTTPHost:       r.Host,
+					semconv.HTTPUserAgent:  r.UserAgent(),
+					semconv.HTTPRemoteAddr: r.RemoteAddr,
 				}),
+				WithRemoteParent(remote),
+				WithSpanKind(SpanKindServer),
 			}
 
-			if traceID != "" {
-				opts = append(opts, WithTraceID(traceID))
-			}
-			if parentSpanID != "" {
-				opts = append(opts, WithParentSpanID(parentSpanID))
-			}
-
-			// Start span
-			span := client.Tracer().StartSpan(
+			// Start span and set trace context in request context
+			ctx, span := client.Tracer().Start(r.Context(),
 				fmt.Sprintf("%s %s", r.Method, path),
 				opts...,
 			)
-
-			// Set trace context in request context
-			ctx := ContextWithSpan(r.Context(), span)
 			r = r.WithContext(ctx)
 
 			// Add trace headers to response
@@ -79,37 +73,55 @@ func HTTPMiddleware(client *Client) func(http.Handler) http.Handler {
 			// Wrap response writer to capture status
 			rw := newResponseWriter(w)
 
-			// Execute handler
-			next.ServeHTTP(rw, r)
+			// Record panics on the span, then let them propagate to the server
+			defer func() {
+				p := recover()
+				if p != nil && p != http.ErrAbortHandler {
+					span.RecordError(&PanicError{Value: p}, WithStackTrace())
+					rw.statusCode = http.StatusInternalServerError
+				}
 
-			duration := time.Since(start)
+				duration := time.Since(start)
 
-			// Set response attributes
-			span.SetAttribute("http.status_code", rw.statusCode)
-			span.SetAttribute("http.response_size", rw.written)
+				// A ServeMux inside the middleware records the pattern it matched
+				if r.Pattern != "" {
+					span.SetAttribute(semconv.HTTPRoute, r.Pattern)
+				}
 
-			if rw.statusCode >= 400 {
-				span.SetStatus(SpanStatusError, fmt.Sprintf("HTTP %d", rw.statusCode))
-				span.SetAttribute("error", true)
-			}
+				// Set response attributes
+				span.SetAttribute(semconv.HTTPStatusCode, rw.statusCode)
+				span.SetAttribute(semconv.HTTPResponseSize, rw.written)
 
-			span.End()
-
-			// Record metrics
-			client.Metrics().RecordDuration("http.request.duration", duration, map[string]string{
-				"method":      r.Method,
-				"path":        path,
-				"status_code": fmt.Sprintf("%d", rw.statusCode),
-			})
-			client.Metrics().Increment("http.request.count", map[string]string{
-				"method":      r.Method,
-				"path":        path,
-				"status_code": fmt.Sprintf("%d", rw.statusCode),
-			})
+				if rw.statusCode >= 400 && p == nil {
+					span.SetStatus(SpanStatusError, fmt.Sprintf("HTTP %d", rw.statusCode))
+					span.SetAttribute(semconv.Error, true)
+				}
+
+				span.End()
+				recordHTTPMetrics(client, r.Method, path, rw.statusCode, duration)
+
+				if p != nil {
+					panic(p)
+				}
+			}()
+
+			// Execute handler
+			next.ServeHTTP(rw, r)
 		})
 	}
 }
 
+// recordHTTPMetrics records the request duration and count metrics for a handled request
+func recordHTTPMetrics(client *Client, method, path string, statusCode int, duration time.Duration) {
+	tags := map[string]string{
+		"method":      method,
+		"path":        path,
+		"status_code": fmt.Sprintf("%d", statusCode),
+	}
+	client.Metrics().RecordDuration("http.request.duration", duration, tags)
+	client.Metrics().Increment("http.request.count", tags)
+}
+
 // HTTPHandlerFunc returns a middleware for http.HandlerFunc
 // Usage: http.HandleFunc("/", omnipulse.HTTPHandlerFunc(client, yourHandlerFunc))
 func HTTPHandlerFunc(client *Client, handler http.HandlerFunc) http.HandlerFunc {
@@ -0,0 +1,97 @@
+package omnipulse
+
+import (
+	cryptorand "crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// IDGenerator creates trace and span IDs as lowercase hex strings
+type IDGenerator interface {
+	// NewTraceID returns a 32-digit trace ID that is not all zeros
+	NewTraceID() string
+	// NewSpanID returns a 16-digit span ID that is not all zeros
+	NewSpanID() string
+}
+
+// RandomIDGenerator returns the default IDGenerator: random IDs from a ChaCha8 generator
+// seeded once from crypto/rand
+func RandomIDGenerator() IDGenerator {
+	return newRandomIDGenerator()
+}
+
+// DeterministicIDGenerator returns an IDGenerator producing the same sequence of IDs for
+// the same seed, for tests
+func DeterministicIDGenerator(seed uint64) IDGenerator {
+	return &randomIDGenerator{src: rand.NewPCG(seed, seed)}
+}
+
+// XRayIDGenerator returns an IDGenerator whose trace IDs start with the current Unix time
+// in seconds, as AWS X-Ray requires: 8 hex digits of time followed by 24 random digits
+func XRayIDGenerator() IDGenerator {
+	return &xrayIDGenerator{random: newRandomIDGenerator(), now: time.Now}
+}
+
+func newRandomIDGenerator() *randomIDGenerator {
+	var seed [32]byte
+	_, _ = cryptorand.Read(seed[:])
+	return &randomIDGenerator{src: rand.NewChaCha8(seed)}
+}
+
+// randomIDGenerator draws IDs from a seeded source, which is not safe for concurrent use
+type randomIDGenerator struct {
+	mu  sync.Mutex
+	src rand.Source
+}
+
+func (g *randomIDGenerator) NewTraceID() string {
+	return g.newID(16)
+}
+
+func (g *randomIDGenerator) NewSpanID() string {
+	return g.newID(8)
+}
+
+// newID returns a hex ID of the given number of bytes that is not all zeros
+func (g *randomIDGenerator) newID(bytes int) string {
+	b := make([]byte, bytes)
+	g.mu.Lock()
+	for {
+		for i := 0; i < len(b); i += 8 {
+			v := g.src.Uint64()
+			for j := 0; j < 8 && i+j < len(b); j++ {
+				b[i+j] = byte(v >> (8 * j))
+			}
+		}
+		if !isZeroBytes(b) {
+			break
+		}
+	}
+	g.mu.Unlock()
+	return hex.EncodeToString(b)
+}
+
+func isZeroBytes(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+type xrayIDGenerator struct {
+	random *randomIDGenerator
+	now    func() time.Time
+}
+
+func (g *xrayIDGenerator) NewTraceID() string {
+	return fmt.Sprintf("%08x", uint32(g.now().Unix())) + g.random.newID(12)
+}
+
+func (g *xrayIDGenerator) NewSpanID() string {
+	return g.random.NewSpanID()
+}
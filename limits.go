@@ -0,0 +1,204 @@
+package omnipulse
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// SpanLimits bounds the size of a span. A negative limit disables it.
+type SpanLimits struct {
+	// AttributeCountLimit caps the attributes on a span; new keys beyond it are dropped (default: 128)
+	AttributeCountLimit int
+	// AttributeValueLengthLimit truncates string values, including those in slices (default: 4096)
+	AttributeValueLengthLimit int
+	// EventCountLimit caps the events on a span; the oldest are dropped (default: 128)
+	EventCountLimit int
+	// LinkCountLimit caps the links on a span; the oldest are dropped (default: 128)
+	LinkCountLimit int
+	// AttributePerEventCountLimit caps the attributes on each event (default: 128)
+	AttributePerEventCountLimit int
+	// AttributePerLinkCountLimit caps the attributes on each link (default: 128)
+	AttributePerLinkCountLimit int
+}
+
+// limits returns the span limits of the span's client
+func (s *Span) limits() SpanLimits {
+	return s.tracer.client.config.SpanLimits
+}
+
+// setAttributeLocked normalizes and stores an attribute, counting it as dropped when the
+// span is full or the value is nil. s.mu must be held.
+func (s *Span) setAttributeLocked(key string, value interface{}) {
+	limits := s.limits()
+	v, ok := normalizeAttribute(value, limits.AttributeValueLengthLimit)
+	if !ok {
+		s.droppedAttributes++
+		return
+	}
+	if _, exists := s.Attributes[key]; !exists && limits.AttributeCountLimit >= 0 && len(s.Attributes) >= limits.AttributeCountLimit {
+		s.droppedAttributes++
+		return
+	}
+	s.Attributes[key] = v
+}
+
+// addEventLocked appends an event, dropping the oldest when the span is full. s.mu must be held.
+func (s *Span) addEventLocked(ev SpanEvent) {
+	limits := s.limits()
+	ev.Attributes, ev.DroppedAttributesCount = limitAttributes(ev.Attributes, limits.AttributePerEventCountLimit, limits.AttributeValueLengthLimit)
+	if limits.EventCountLimit >= 0 && len(s.Events) >= limits.EventCountLimit {
+		s.droppedEvents++
+		if limits.EventCountLimit == 0 || len(s.Events) == 0 {
+			return
+		}
+		s.Events = append(s.Events[:0:0], s.Events[1:]...)
+	}
+	s.Events = append(s.Events, ev)
+}
+
+// addLinkLocked appends a link, dropping the oldest when the span is full. s.mu must be held.
+func (s *Span) addLinkLocked(link SpanLink) {
+	limits := s.limits()
+	link.Attributes, link.DroppedAttributesCount = limitAttributes(link.Attributes, limits.AttributePerLinkCountLimit, limits.AttributeValueLengthLimit)
+	if limits.LinkCountLimit >= 0 && len(s.Links) >= limits.LinkCountLimit {
+		s.droppedLinks++
+		if limits.LinkCountLimit == 0 || len(s.Links) == 0 {
+			return
+		}
+		s.Links = append(s.Links[:0:0], s.Links[1:]...)
+	}
+	s.Links = append(s.Links, link)
+}
+
+// limitAttributes returns a normalized copy of attrs with at most count entries, and the
+// number of entries dropped
+func limitAttributes(attrs map[string]interface{}, count, valueLength int) (map[string]interface{}, int) {
+	if attrs == nil {
+		return nil, 0
+	}
+	out := make(map[string]interface{}, len(attrs))
+	dropped := 0
+	for k, v := range attrs {
+		nv, ok := normalizeAttribute(v, valueLength)
+		if !ok || (count >= 0 && len(out) >= count) {
+			dropped++
+			continue
+		}
+		out[k] = nv
+	}
+	return out, dropped
+}
+
+// normalizeAttribute converts v to one of the attribute types the exporters understand:
+// string, bool, int64, float64 or a slice of one of them. Other values, and NaN and ±Inf
+// which JSON cannot encode, are formatted as strings. It reports false for nil.
+func normalizeAttribute(v interface{}, maxLen int) (interface{}, bool) {
+	switch val := v.(type) {
+	case nil:
+		return nil, false
+	case string:
+		return truncateAttribute(val, maxLen), true
+	case bool:
+		return val, true
+	case int:
+		return int64(val), true
+	case int8:
+		return int64(val), true
+	case int16:
+		return int64(val), true
+	case int32:
+		return int64(val), true
+	case int64:
+		return val, true
+	case uint:
+		return uintAttribute(uint64(val)), true
+	case uint8:
+		return int64(val), true
+	case uint16:
+		return int64(val), true
+	case uint32:
+		return int64(val), true
+	case uint64:
+		return uintAttribute(val), true
+	case float32:
+		return floatAttribute(float64(val)), true
+	case float64:
+		return floatAttribute(val), true
+	case time.Duration:
+		return int64(val), true
+	case time.Time:
+		return val.Format(time.RFC3339Nano), true
+	case []string:
+		out := make([]string, len(val))
+		for i, s := range val {
+			out[i] = truncateAttribute(s, maxLen)
+		}
+		return out, true
+	case []bool:
+		return append([]bool(nil), val...), true
+	case []int:
+		out := make([]int64, len(val))
+		for i, n := range val {
+			out[i] = int64(n)
+		}
+		return out, true
+	case []int64:
+		return append([]int64(nil), val...), true
+	case []float64:
+		for _, f := range val {
+			if !isFinite(f) {
+				out := make([]string, len(val))
+				for i, f := range val {
+					out[i] = strconv.FormatFloat(f, 'g', -1, 64)
+				}
+				return out, true
+			}
+		}
+		return append([]float64(nil), val...), true
+	case []interface{}:
+		out := make([]string, len(val))
+		for i, e := range val {
+			out[i] = truncateAttribute(fmt.Sprint(e), maxLen)
+		}
+		return out, true
+	case error:
+		return truncateAttribute(val.Error(), maxLen), true
+	case fmt.Stringer:
+		return truncateAttribute(val.String(), maxLen), true
+	}
+	return truncateAttribute(fmt.Sprintf("%+v", v), maxLen), true
+}
+
+// floatAttribute formats NaN and ±Inf as strings
+func floatAttribute(v float64) interface{} {
+	if !isFinite(v) {
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+	return v
+}
+
+func isFinite(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
+
+// uintAttribute keeps unsigned values that do not fit in int64 as float64
+func uintAttribute(v uint64) interface{} {
+	if v > math.MaxInt64 {
+		return float64(v)
+	}
+	return int64(v)
+}
+
+// truncateAttribute shortens s to at most maxLen bytes without splitting a UTF-8 sequence
+func truncateAttribute(s string, maxLen int) string {
+	if maxLen < 0 || len(s) <= maxLen {
+		return s
+	}
+	for maxLen > 0 && !utf8.RuneStart(s[maxLen]) {
+		maxLen--
+	}
+	return s[:maxLen]
+}
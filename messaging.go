@@ -0,0 +1,144 @@
+package omnipulse
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/masbenx/omnipulse-go/semconv"
+)
+
+// MapCarrier adapts message headers held in a map to Carrier
+type MapCarrier map[string]string
+
+func (m MapCarrier) Get(key string) string {
+	return m[key]
+}
+
+func (m MapCarrier) Set(key, value string) {
+	m[key] = value
+}
+
+func (m MapCarrier) Keys() []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// InjectMessageHeaders writes the context of the span in ctx into message headers
+func (c *Client) InjectMessageHeaders(ctx context.Context, headers map[string]string) {
+	c.Inject(ctx, MapCarrier(headers))
+}
+
+// ExtractMessageHeaders reads the producer's span context from message headers
+func (c *Client) ExtractMessageHeaders(headers map[string]string) SpanContext {
+	return c.Extract(MapCarrier(headers))
+}
+
+// StartProducerSpan starts a producer span for publishing a message to destination and
+// injects its context into headers
+func (c *Client) StartProducerSpan(ctx context.Context, system, destination string, headers map[string]string, opts ...SpanOption) (context.Context, *Span) {
+	opts = append([]SpanOption{
+		WithSpanKind(SpanKindProducer),
+		WithAttributes(map[string]interface{}{
+			semconv.MessagingSystem:      system,
+			semconv.MessagingDestination: destination,
+			semconv.MessagingOperation:   "publish",
+		}),
+	}, opts...)
+
+	ctx, span := c.Tracer().Start(ctx, fmt.Sprintf("%s publish", destination), opts...)
+	c.InjectMessageHeaders(ctx, headers)
+	return ctx, span
+}
+
+// StartConsumerSpan starts a consumer span for processing one message from destination,
+// linked to the producer context in headers. Without a span in ctx the consumer span also
+// continues the producer's trace.
+func (c *Client) StartConsumerSpan(ctx context.Context, system, destination string, headers map[string]string, opts ...SpanOption) (context.Context, *Span) {
+	return c.startConsumerSpan(ctx, fmt.Sprintf("%s process", destination), system, destination, headers, opts)
+}
+
+func (c *Client) startConsumerSpan(ctx context.Context, name, system, destination string, headers map[string]string, opts []SpanOption) (context.Context, *Span) {
+	producer := c.ExtractMessageHeaders(headers)
+
+	opts = append([]SpanOption{
+		WithSpanKind(SpanKindConsumer),
+		WithAttributes(map[string]interface{}{
+			semconv.MessagingSystem:      system,
+			semconv.MessagingDestination: destination,
+			semconv.MessagingOperation:   "process",
+		}),
+	}, opts...)
+	if producer.IsValid() && producer.SpanID != "" {
+		opts = append(opts, WithLinks(SpanLink{TraceID: producer.TraceID, SpanID: producer.SpanID}))
+	}
+	if SpanFromContext(ctx) == nil {
+		opts = append(opts, WithRemoteParent(producer))
+	}
+
+	return c.Tracer().Start(ctx, name, opts...)
+}
+
+// StartBatchConsumerSpan starts one consumer span for a batch of messages from
+// destination, linked to the producer context of every message. The span is a child of
+// the span or remote span context in ctx, or a new trace.
+func (c *Client) StartBatchConsumerSpan(ctx context.Context, system, destination string, batch []map[string]string, opts ...SpanOption) (context.Context, *Span) {
+	var links []SpanLink
+	for _, headers := range batch {
+		if producer := c.ExtractMessageHeaders(headers); producer.IsValid() && producer.SpanID != "" {
+			links = append(links, SpanLink{TraceID: producer.TraceID, SpanID: producer.SpanID})
+		}
+	}
+
+	opts = append([]SpanOption{
+		WithSpanKind(SpanKindConsumer),
+		WithAttributes(map[string]interface{}{
+			semconv.MessagingSystem:            system,
+			semconv.MessagingDestination:       destination,
+			semconv.MessagingOperation:         "process",
+			semconv.MessagingBatchMessageCount: len(batch),
+		}),
+		WithLinks(links...),
+	}, opts...)
+
+	return c.Tracer().Start(ctx, fmt.Sprintf("%s process", destination), opts...)
+}
+
+// TraceJob runs fn as a background job in a consumer span linked to the enqueuer's context
+// in headers, then records the job with LogJob. headers may be nil. An error returned by
+// fn, or a panic, is recorded on the span and the job; panics are re-raised.
+func (c *Client) TraceJob(ctx context.Context, name, queue string, headers map[string]string, fn func(ctx context.Context) error) error {
+	ctx, span := c.startConsumerSpan(ctx, name, "job", queue, headers, []SpanOption{
+		WithAttributes(map[string]interface{}{"job.name": name}),
+	})
+
+	start := time.Now()
+	finish := func(err error, opts ...ErrorOption) {
+		job := JobData{
+			JobName:    name,
+			Queue:      queue,
+			DurationMs: int(time.Since(start).Milliseconds()),
+			Status:     "success",
+		}
+		if err != nil {
+			job.Status = "failed"
+			job.Error = err.Error()
+		}
+		span.RecordError(err, opts...)
+		span.End()
+		c.LogJob(job)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			finish(&PanicError{Value: p}, WithStackTrace())
+			panic(p)
+		}
+	}()
+
+	err := fn(ctx)
+	finish(err)
+	return err
+}
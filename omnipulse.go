@@ -1,23 +1,21 @@
 package omnipulse
 
 import (
-	"bytes"
-	"compress/gzip"
 	"context"
-	"encoding/json"
 	"fmt"
-	"net/http"
+	"log/slog"
 	"os"
 	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 // Config holds the SDK configuration
 type Config struct {
-	// APIUrl is the OmniPulse backend URL (required)
+	// APIUrl is the OmniPulse backend URL (required unless Exporter is set)
 	APIUrl string
-	// IngestKey is the X-Ingest-Key for authentication (required)
+	// IngestKey is the X-Ingest-Key for authentication (required unless Exporter is set)
 	IngestKey string
 	// Environment is the deployment environment (default: production)
 	Environment string
@@ -25,22 +23,92 @@ type Config struct {
 	ServiceName string
 	// Version is your application version
 	Version string
-	// Debug enables debug logging
+	// Debug writes the SDK's own diagnostics to stderr when DiagnosticLogger is not set
 	Debug bool
+	// DiagnosticLogger receives the SDK's own diagnostics: send failures, retries, drops and profiler errors
+	DiagnosticLogger *slog.Logger
+	// ErrorHandler, if set, is called with errors that cannot be returned to the caller
+	ErrorHandler func(error)
 	// BatchSize is the number of items to batch before sending (default: 100)
 	BatchSize int
 	// FlushInterval is how often to flush the buffer (default: 5s)
 	FlushInterval time.Duration
 	// Timeout for HTTP requests (default: 5s)
 	Timeout time.Duration
+	// MaxPayloadBytes caps the uncompressed body of one ingest request; larger batches are
+	// split and oversized items truncated (default: 1MB)
+	MaxPayloadBytes int
 	// EnableProfiling enables continuous CPU profiling (default: false)
 	EnableProfiling bool
+	// Retry controls retrying of failed requests (default: 3 attempts, 500ms-30s backoff)
+	Retry RetryConfig
+	// SpoolDir enables persisting undeliverable batches to disk for later replay (default: disabled)
+	SpoolDir string
+	// SpoolMaxBytes caps the spool size on disk; oldest batches are evicted first (default: 64MB)
+	SpoolMaxBytes int64
+	// SpoolMaxAge discards spooled batches older than this on replay (default: 24h)
+	SpoolMaxAge time.Duration
+	// MaxBufferItems caps the number of buffered items per signal (default: 10000)
+	MaxBufferItems int
+	// MaxBufferBytes caps the approximate size of buffered items per signal (default: 8MB)
+	MaxBufferBytes int
+	// OverflowPolicy decides what happens when a buffer is full (default: OverflowDropNewest)
+	OverflowPolicy OverflowPolicy
+	// BlockTimeout is how long OverflowBlock waits for room before dropping (default: 1s)
+	BlockTimeout time.Duration
+	// ShutdownTimeout bounds how long Close waits for buffered data to be sent (default: 10s)
+	ShutdownTimeout time.Duration
+	// SenderQueueSize is the number of ready batches queued per signal (default: 8)
+	SenderQueueSize int
+	// SelfTelemetry records the SDK's own delivery stats as omnipulse.sdk.* metrics on every flush interval (default: false)
+	SelfTelemetry bool
+	// Exporter delivers telemetry; use MultiExporter to send to several backends (default: HTTPExporter)
+	Exporter Exporter
+	// Propagator reads and writes trace context headers (default: W3C traceparent, then X-OmniPulse-*)
+	Propagator Propagator
+	// Sampler decides which spans are exported (default: ParentBased(AlwaysSample()))
+	Sampler Sampler
+	// TailSampling holds spans until their local trace completes and keeps only traces
+	// matching its policies (default: disabled)
+	TailSampling TailSamplingConfig
+	// SpanLimits bounds the attributes, events and links recorded on each span
+	SpanLimits SpanLimits
+	// SpanProcessors run in order on every span as it starts and ends, and can change or
+	// drop finished spans before export (default: none)
+	SpanProcessors []SpanProcessor
+	// IDGenerator creates trace and span IDs (default: RandomIDGenerator())
+	IDGenerator IDGenerator
+}
+
+// Signal identifies a kind of telemetry handled by the client
+type Signal int
+
+const (
+	SignalLogs Signal = iota
+	SignalSpans
+	SignalMetrics
+	SignalJobs
+)
+
+func (s Signal) String() string {
+	switch s {
+	case SignalLogs:
+		return "logs"
+	case SignalSpans:
+		return "spans"
+	case SignalMetrics:
+		return "metrics"
+	case SignalJobs:
+		return "jobs"
+	}
+	return fmt.Sprintf("signal(%d)", int(s))
 }
 
 // Client is the main OmniPulse SDK client
 type Client struct {
 	config     Config
-	httpClient *http.Client
+	exporter   Exporter
+	diagLogger *slog.Logger
 	logger     *Logger
 	tracer     *Tracer
 	metrics    *Metrics
@@ -49,13 +117,35 @@ type Client struct {
 	spanBuffer   []SpanData
 	metricBuffer []MetricData
 	jobBuffer    []JobData
+	bufferBytes  [numSignals]int
 	bufferMu     sync.Mutex
+	spaceCh      chan struct{}
+	flushCh      chan struct{}
+	dropped      [numSignals]atomic.Uint64
+	stats        clientStats
+
+	spool *spool
+	tail  *tailSampler
+
+	queues    [numSignals]chan *batch
+	senderWg  sync.WaitGroup
+	flushMu   sync.Mutex
+	flushing  *flushCall
+	pending   *flushCall
+	flushIdle chan struct{} // closed while no flush loop is running
+	closed    bool
+
+	closeOnce sync.Once
+	closeErr  error
 
 	ctx    context.Context
 	cancel context.CancelFunc
 	wg     sync.WaitGroup
 }
 
+// defaultEnvironment is used when Config.Environment is empty
+const defaultEnvironment = "production"
+
 // New creates a new OmniPulse client
 func New(cfg Config) (*Client, error) {
 	if cfg.APIUrl == "" {
@@ -64,13 +154,13 @@ func New(cfg Config) (*Client, error) {
 	if cfg.IngestKey == "" {
 		cfg.IngestKey = os.Getenv("OMNIPULSE_INGEST_KEY")
 	}
-	if cfg.APIUrl == "" || cfg.IngestKey == "" {
+	if cfg.Exporter == nil && (cfg.APIUrl == "" || cfg.IngestKey == "") {
 		return nil, fmt.Errorf("APIUrl and IngestKey are required")
 	}
 
 	// Set defaults
 	if cfg.Environment == "" {
-		cfg.Environment = "production"
+		cfg.Environment = defaultEnvironment
 	}
 	if cfg.BatchSize == 0 {
 		cfg.BatchSize = 100
@@ -81,35 +171,138 @@ func New(cfg Config) (*Client, error) {
 	if cfg.Timeout == 0 {
 		cfg.Timeout = 5 * time.Second
 	}
+	if cfg.MaxPayloadBytes == 0 {
+		cfg.MaxPayloadBytes = defaultMaxPayloadBytes
+	}
+	if cfg.Retry.MaxAttempts == 0 {
+		cfg.Retry.MaxAttempts = 3
+	}
+	if cfg.Retry.InitialBackoff == 0 {
+		cfg.Retry.InitialBackoff = 500 * time.Millisecond
+	}
+	if cfg.Retry.MaxBackoff == 0 {
+		cfg.Retry.MaxBackoff = 30 * time.Second
+	}
+	if cfg.Retry.Jitter == 0 {
+		cfg.Retry.Jitter = 0.2
+	}
+	if cfg.MaxBufferItems == 0 {
+		cfg.MaxBufferItems = 10000
+	}
+	if cfg.MaxBufferBytes == 0 {
+		cfg.MaxBufferBytes = 8 << 20
+	}
+	if cfg.BlockTimeout == 0 {
+		cfg.BlockTimeout = time.Second
+	}
+	if cfg.ShutdownTimeout == 0 {
+		cfg.ShutdownTimeout = 10 * time.Second
+	}
+	if cfg.SenderQueueSize == 0 {
+		cfg.SenderQueueSize = 8
+	}
+	if cfg.Sampler == nil {
+		cfg.Sampler = ParentBased(AlwaysSample())
+	}
+	if cfg.TailSampling.DecisionWait == 0 {
+		cfg.TailSampling.DecisionWait = 30 * time.Second
+	}
+	if cfg.TailSampling.MaxSpans == 0 {
+		cfg.TailSampling.MaxSpans = 10000
+	}
+	if cfg.SpanLimits.AttributeCountLimit == 0 {
+		cfg.SpanLimits.AttributeCountLimit = 128
+	}
+	if cfg.SpanLimits.AttributeValueLengthLimit == 0 {
+		cfg.SpanLimits.AttributeValueLengthLimit = 4096
+	}
+	if cfg.SpanLimits.EventCountLimit == 0 {
+		cfg.SpanLimits.EventCountLimit = 128
+	}
+	if cfg.SpanLimits.LinkCountLimit == 0 {
+		cfg.SpanLimits.LinkCountLimit = 128
+	}
+	if cfg.SpanLimits.AttributePerEventCountLimit == 0 {
+		cfg.SpanLimits.AttributePerEventCountLimit = 128
+	}
+	if cfg.SpanLimits.AttributePerLinkCountLimit == 0 {
+		cfg.SpanLimits.AttributePerLinkCountLimit = 128
+	}
+	if cfg.IDGenerator == nil {
+		cfg.IDGenerator = RandomIDGenerator()
+	}
+	if cfg.Propagator == nil {
+		cfg.Propagator = defaultPropagator()
+	}
+	if cfg.SpoolMaxBytes == 0 {
+		cfg.SpoolMaxBytes = 64 << 20
+	}
+	if cfg.SpoolMaxAge == 0 {
+		cfg.SpoolMaxAge = 24 * time.Hour
+	}
+
+	var sp *spool
+	if cfg.SpoolDir != "" {
+		var err error
+		sp, err = openSpool(cfg.SpoolDir, cfg.SpoolMaxBytes, cfg.SpoolMaxAge)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 
 	c := &Client{
-		config: cfg,
-		httpClient: &http.Client{
-			Timeout: cfg.Timeout,
-		},
+		config:       cfg,
+		exporter:     cfg.Exporter,
+		diagLogger:   newDiagnosticLogger(cfg),
 		ctx:          ctx,
 		cancel:       cancel,
 		logBuffer:    make([]LogEntry, 0, cfg.BatchSize),
 		spanBuffer:   make([]SpanData, 0, cfg.BatchSize),
 		metricBuffer: make([]MetricData, 0, cfg.BatchSize),
 		jobBuffer:    make([]JobData, 0, cfg.BatchSize),
+		spaceCh:      make(chan struct{}),
+		flushIdle:    make(chan struct{}),
+		flushCh:      make(chan struct{}, 1),
+		spool:        sp,
+	}
+	close(c.flushIdle)
+
+	if c.exporter == nil {
+		c.exporter = NewHTTPExporter(cfg)
 	}
 
 	c.logger = newLogger(c)
 	c.tracer = newTracer(c)
 	c.metrics = newMetrics(c)
 
+	c.startSenders()
+
 	// Start background flush worker
 	c.wg.Add(1)
 	go c.flushWorker()
 
+	if len(cfg.TailSampling.Policies) > 0 {
+		c.tail = newTailSampler(c, cfg.TailSampling)
+		c.wg.Add(1)
+		go c.tailWorker()
+	}
+
 	if c.config.EnableProfiling {
 		c.wg.Add(1)
 		go c.startProfiler()
 	}
 
+	// Deliver anything left over from a previous run
+	if c.spool != nil {
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			_ = c.replaySpool(c.ctx)
+		}()
+	}
+
 	return c, nil
 }
 
@@ -141,67 +334,85 @@ func (c *Client) Test() error {
 	return c.Flush()
 }
 
-// Flush immediately sends all buffered data
+// Flush immediately sends all buffered data. Calls made while a flush is running are
+// coalesced into a single follow-up flush.
 func (c *Client) Flush() error {
-	c.bufferMu.Lock()
-	logs := c.logBuffer
-	spans := c.spanBuffer
-	metrics := c.metricBuffer
-	jobs := c.jobBuffer
-	c.logBuffer = make([]LogEntry, 0, c.config.BatchSize)
-	c.spanBuffer = make([]SpanData, 0, c.config.BatchSize)
-	c.metricBuffer = make([]MetricData, 0, c.config.BatchSize)
-	c.jobBuffer = make([]JobData, 0, c.config.BatchSize)
-	c.bufferMu.Unlock()
-
-	var lastErr error
-
-	if len(logs) > 0 {
-		if err := c.sendLogs(logs); err != nil {
-			lastErr = err
-			if c.config.Debug {
-				fmt.Printf("[omnipulse] failed to send logs: %v\n", err)
-			}
-		}
-	}
+	return c.FlushContext(context.Background())
+}
 
-	if len(spans) > 0 {
-		if err := c.sendSpans(spans); err != nil {
-			lastErr = err
-			if c.config.Debug {
-				fmt.Printf("[omnipulse] failed to send spans: %v\n", err)
-			}
-		}
+// FlushContext is like Flush but gives up queueing batches once ctx is done; batches
+// already handed to an exporter are sent with ctx and fail when it expires.
+func (c *Client) FlushContext(ctx context.Context) error {
+	_, err := c.flushCoalesced(ctx)
+	return err
+}
+
+// Close flushes remaining data and shuts down the client, waiting at most
+// Config.ShutdownTimeout. Calling Close more than once returns the result of the first call.
+func (c *Client) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), c.config.ShutdownTimeout)
+	defer cancel()
+	return c.Shutdown(ctx)
+}
+
+// Shutdown stops background work, sends all buffered data and waits for in-flight sends
+// within ctx's deadline, then shuts down the exporter. Telemetry that could not be
+// delivered or spooled is reported as a *ShutdownError. Calling Shutdown more than once
+// returns the result of the first call.
+func (c *Client) Shutdown(ctx context.Context) error {
+	c.closeOnce.Do(func() {
+		c.closeErr = c.shutdown(ctx)
+	})
+	return c.closeErr
+}
+
+func (c *Client) shutdown(ctx context.Context) error {
+	c.cancel()
+	c.wg.Wait()
+	c.shutdownProcessors(ctx)
+	if c.tail != nil {
+		c.tail.flush()
+	}
+	dropped, err := c.flushCoalesced(ctx)
+	if stopErr := c.stopSenders(ctx); err == nil {
+		err = stopErr
 	}
 
-	if len(metrics) > 0 {
-		if err := c.sendMetrics(metrics); err != nil {
-			lastErr = err
-			if c.config.Debug {
-				fmt.Printf("[omnipulse] failed to send metrics: %v\n", err)
-			}
-		}
+	if shutdownErr := c.exporter.Shutdown(ctx); err == nil {
+		err = shutdownErr
 	}
+	if err != nil {
+		return &ShutdownError{Dropped: dropped, Err: err}
+	}
+	return nil
+}
 
-	if len(jobs) > 0 {
-		for _, job := range jobs {
-			if err := c.sendJob(job); err != nil {
-				lastErr = err
-				if c.config.Debug {
-					fmt.Printf("[omnipulse] failed to send job: %v\n", err)
-				}
-			}
-		}
+// ShutdownError reports telemetry that was lost while shutting down
+type ShutdownError struct {
+	// Dropped is the number of items per signal that were neither delivered nor spooled
+	Dropped map[Signal]int
+	// Err is the last error encountered
+	Err error
+}
+
+func (e *ShutdownError) Error() string {
+	total := 0
+	for _, n := range e.Dropped {
+		total += n
 	}
+	return fmt.Sprintf("omnipulse: shutdown dropped %d items: %v", total, e.Err)
+}
 
-	return lastErr
+func (e *ShutdownError) Unwrap() error {
+	return e.Err
 }
 
-// Close flushes remaining data and shuts down the client
-func (c *Client) Close() error {
-	c.cancel()
-	c.wg.Wait()
-	return c.Flush()
+// SpoolDepth returns the number of batches waiting in the on-disk spool and their size in bytes
+func (c *Client) SpoolDepth() (batches int, bytes int64) {
+	if c.spool == nil {
+		return 0, 0
+	}
+	return c.spool.depth()
 }
 
 func (c *Client) flushWorker() {
@@ -212,6 +423,11 @@ func (c *Client) flushWorker() {
 	for {
 		select {
 		case <-ticker.C:
+			if c.config.SelfTelemetry {
+				c.emitSelfTelemetry()
+			}
+			_ = c.Flush()
+		case <-c.flushCh:
 			_ = c.Flush()
 		case <-c.ctx.Done():
 			return
@@ -220,61 +436,49 @@ func (c *Client) flushWorker() {
 }
 
 func (c *Client) addLog(entry LogEntry) {
-	c.bufferMu.Lock()
-	c.logBuffer = append(c.logBuffer, entry)
-	shouldFlush := len(c.logBuffer) >= c.config.BatchSize
-	c.bufferMu.Unlock()
-
-	if shouldFlush {
-		go c.Flush()
+	if enqueue(c, SignalLogs, &c.logBuffer, entry, logEntrySize, c.config.OverflowPolicy) {
+		c.triggerFlush()
 	}
 }
 
 func (c *Client) addSpan(span SpanData) {
-	c.bufferMu.Lock()
-	c.spanBuffer = append(c.spanBuffer, span)
-	shouldFlush := len(c.spanBuffer) >= c.config.BatchSize
-	c.bufferMu.Unlock()
-
-	if shouldFlush {
-		go c.Flush()
+	if enqueue(c, SignalSpans, &c.spanBuffer, span, spanDataSize, c.config.OverflowPolicy) {
+		c.triggerFlush()
 	}
 }
 
 func (c *Client) addMetric(metric MetricData) {
-	c.bufferMu.Lock()
-	c.metricBuffer = append(c.metricBuffer, metric)
-	shouldFlush := len(c.metricBuffer) >= c.config.BatchSize
-	c.bufferMu.Unlock()
-
-	if shouldFlush {
-		go c.Flush()
+	if enqueue(c, SignalMetrics, &c.metricBuffer, metric, metricDataSize, c.config.OverflowPolicy) {
+		c.triggerFlush()
 	}
 }
 
-func (c *Client) sendLogs(logs []LogEntry) error {
-	payload := map[string]interface{}{
-		"entries": logs,
+// addMetricNoWait is like addMetric but drops the metric instead of waiting under
+// OverflowBlock when the buffer is full
+func (c *Client) addMetricNoWait(metric MetricData) {
+	policy := c.config.OverflowPolicy
+	if policy == OverflowBlock {
+		policy = OverflowDropNewest
+	}
+	if enqueue(c, SignalMetrics, &c.metricBuffer, metric, metricDataSize, policy) {
+		c.triggerFlush()
 	}
-	return c.send("/api/ingest/app-logs", payload)
 }
 
-func (c *Client) sendSpans(spans []SpanData) error {
-	payload := map[string]interface{}{
-		"spans": spans,
-	}
-	return c.send("/api/ingest/app-traces", payload)
+func (c *Client) sendLogs(ctx context.Context, logs []LogEntry) ([]LogEntry, int, error) {
+	return sendBatch(c, ctx, SignalLogs, logs, c.exporter.ExportLogs)
 }
 
-func (c *Client) sendMetrics(metrics []MetricData) error {
-	payload := map[string]interface{}{
-		"metrics": metrics,
-	}
-	return c.send("/api/ingest/app-metrics", payload)
+func (c *Client) sendSpans(ctx context.Context, spans []SpanData) ([]SpanData, int, error) {
+	return sendBatch(c, ctx, SignalSpans, spans, c.exporter.ExportSpans)
+}
+
+func (c *Client) sendMetrics(ctx context.Context, metrics []MetricData) ([]MetricData, int, error) {
+	return sendBatch(c, ctx, SignalMetrics, metrics, c.exporter.ExportMetrics)
 }
 
-func (c *Client) sendJob(job JobData) error {
-	return c.send("/api/ingest/app-job", job)
+func (c *Client) sendJobs(ctx context.Context, jobs []JobData) ([]JobData, int, error) {
+	return sendBatch(c, ctx, SignalJobs, jobs, c.exporter.ExportJobs)
 }
 
 func (c *Client) LogJob(job JobData) {
@@ -282,13 +486,8 @@ func (c *Client) LogJob(job JobData) {
 		job.Ts = time.Now().UTC().Format(time.RFC3339)
 	}
 
-	c.bufferMu.Lock()
-	c.jobBuffer = append(c.jobBuffer, job)
-	shouldFlush := len(c.jobBuffer) >= c.config.BatchSize
-	c.bufferMu.Unlock()
-
-	if shouldFlush {
-		go c.Flush()
+	if enqueue(c, SignalJobs, &c.jobBuffer, job, jobDataSize, c.config.OverflowPolicy) {
+		c.triggerFlush()
 	}
 }
 
@@ -302,43 +501,4 @@ type JobData struct {
 	Ts         string `json:"ts"`
 }
 
-func (c *Client) send(endpoint string, payload interface{}) error {
-	data, err := json.Marshal(payload)
-	if err != nil {
-		return fmt.Errorf("failed to marshal payload: %w", err)
-	}
-
-	// Compress with gzip
-	var buf bytes.Buffer
-	gz := gzip.NewWriter(&buf)
-	if _, err := gz.Write(data); err != nil {
-		return fmt.Errorf("failed to compress payload: %w", err)
-	}
-	if err := gz.Close(); err != nil {
-		return fmt.Errorf("failed to close gzip writer: %w", err)
-	}
-
-	req, err := http.NewRequestWithContext(c.ctx, "POST", c.config.APIUrl+endpoint, &buf)
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Content-Encoding", "gzip")
-	req.Header.Set("X-Ingest-Key", c.config.IngestKey)
-	req.Header.Set("User-Agent", fmt.Sprintf("omnipulse-go-sdk/%s", Version))
-
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to send request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode >= 400 {
-		return fmt.Errorf("server returned status %d", resp.StatusCode)
-	}
-
-	return nil
-}
-
 const Version = "1.1.0"
@@ -0,0 +1,522 @@
+package omnipulse
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OTLPConfig configures the OTLP/HTTP exporter
+type OTLPConfig struct {
+	// Endpoint is the collector base URL, e.g. http://localhost:4318 (required)
+	Endpoint string
+	// Headers are added to every request, e.g. for collector authentication
+	Headers map[string]string
+	// Timeout for HTTP requests (default: 10s)
+	Timeout time.Duration
+	// DisableCompression sends request bodies uncompressed (default: gzip)
+	DisableCompression bool
+}
+
+// OTLPExporter sends spans, logs and metrics to an OpenTelemetry Collector using
+// OTLP/HTTP with JSON encoding. Jobs are exported as log records; profiles are not
+// supported and are silently skipped.
+type OTLPExporter struct {
+	endpoint   string
+	headers    map[string]string
+	gzip       bool
+	resource   otlpResource
+	httpClient *http.Client
+}
+
+// NewOTLPExporter creates an OTLP/HTTP exporter. Resource attributes are taken from
+// cfg's ServiceName, Version and Environment, which defaults to "production" as in New.
+func NewOTLPExporter(cfg Config, otlp OTLPConfig) (*OTLPExporter, error) {
+	if otlp.Endpoint == "" {
+		return nil, fmt.Errorf("OTLP endpoint is required")
+	}
+	if otlp.Timeout == 0 {
+		otlp.Timeout = 10 * time.Second
+	}
+
+	attrs := map[string]interface{}{
+		"telemetry.sdk.name":     "omnipulse-go",
+		"telemetry.sdk.language": "go",
+		"telemetry.sdk.version":  Version,
+	}
+	if cfg.ServiceName != "" {
+		attrs["service.name"] = cfg.ServiceName
+	}
+	if cfg.Version != "" {
+		attrs["service.version"] = cfg.Version
+	}
+	environment := cfg.Environment
+	if environment == "" {
+		environment = defaultEnvironment
+	}
+	attrs["deployment.environment"] = environment
+
+	return &OTLPExporter{
+		endpoint: strings.TrimRight(otlp.Endpoint, "/"),
+		headers:  otlp.Headers,
+		gzip:     !otlp.DisableCompression,
+		resource: otlpResource{Attributes: otlpAttributes(attrs)},
+		httpClient: &http.Client{
+			Timeout: otlp.Timeout,
+		},
+	}, nil
+}
+
+// ExportSpans sends spans to /v1/traces
+func (e *OTLPExporter) ExportSpans(ctx context.Context, spans []SpanData) error {
+	out := make([]otlpSpan, 0, len(spans))
+	for _, s := range spans {
+		out = append(out, otlpSpanFrom(s))
+	}
+	payload := map[string]interface{}{
+		"resourceSpans": []map[string]interface{}{{
+			"resource":   e.resource,
+			"scopeSpans": []map[string]interface{}{{"scope": otlpScope(), "spans": out}},
+		}},
+	}
+	return e.send(ctx, "/v1/traces", payload)
+}
+
+// ExportLogs sends logs to /v1/logs
+func (e *OTLPExporter) ExportLogs(ctx context.Context, logs []LogEntry) error {
+	out := make([]otlpLogRecord, 0, len(logs))
+	for _, l := range logs {
+		out = append(out, otlpLogRecordFrom(l))
+	}
+	return e.sendLogRecords(ctx, out)
+}
+
+// ExportJobs sends jobs to /v1/logs as log records with job.* attributes
+func (e *OTLPExporter) ExportJobs(ctx context.Context, jobs []JobData) error {
+	out := make([]otlpLogRecord, 0, len(jobs))
+	for _, j := range jobs {
+		out = append(out, otlpJobRecordFrom(j))
+	}
+	return e.sendLogRecords(ctx, out)
+}
+
+// ExportMetrics sends metrics to /v1/metrics. Counters become non-monotonic delta sums and
+// histogram observations become single-sample delta histograms.
+func (e *OTLPExporter) ExportMetrics(ctx context.Context, metrics []MetricData) error {
+	var out []map[string]interface{}
+	index := make(map[string]int)
+	for _, m := range metrics {
+		key := string(m.Type) + "\x00" + m.Name
+		i, ok := index[key]
+		if !ok {
+			i = len(out)
+			index[key] = i
+			out = append(out, otlpMetricFor(m))
+		}
+		appendOTLPDataPoint(out[i], m)
+	}
+	payload := map[string]interface{}{
+		"resourceMetrics": []map[string]interface{}{{
+			"resource":     e.resource,
+			"scopeMetrics": []map[string]interface{}{{"scope": otlpScope(), "metrics": out}},
+		}},
+	}
+	return e.send(ctx, "/v1/metrics", payload)
+}
+
+// ExportProfile is a no-op; OTLP profiling is not supported
+func (e *OTLPExporter) ExportProfile(ctx context.Context, profile Profile) error {
+	return nil
+}
+
+// Shutdown closes idle connections
+func (e *OTLPExporter) Shutdown(ctx context.Context) error {
+	e.httpClient.CloseIdleConnections()
+	return nil
+}
+
+func (e *OTLPExporter) sendLogRecords(ctx context.Context, records []otlpLogRecord) error {
+	payload := map[string]interface{}{
+		"resourceLogs": []map[string]interface{}{{
+			"resource":  e.resource,
+			"scopeLogs": []map[string]interface{}{{"scope": otlpScope(), "logRecords": records}},
+		}},
+	}
+	return e.send(ctx, "/v1/logs", payload)
+}
+
+func (e *OTLPExporter) send(ctx context.Context, path string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	if e.gzip {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		if _, err := gz.Write(data); err != nil {
+			return fmt.Errorf("failed to compress payload: %w", err)
+		}
+		if err := gz.Close(); err != nil {
+			return fmt.Errorf("failed to close gzip writer: %w", err)
+		}
+		data = buf.Bytes()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", e.endpoint+path, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if e.gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	req.Header.Set("User-Agent", fmt.Sprintf("omnipulse-go-sdk/%s", Version))
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return &SendError{Err: err}
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode >= 400 {
+		return newStatusError(resp)
+	}
+
+	recordBytesSent(ctx, len(data))
+	return nil
+}
+
+// OTLP JSON model. Field names follow the protobuf JSON mapping; 64-bit integers are
+// encoded as strings and trace/span IDs as hex.
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpKeyValue struct {
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Events            []otlpEvent    `json:"events,omitempty"`
+	Links             []otlpLink     `json:"links,omitempty"`
+	Status            otlpStatus     `json:"status"`
+
+	DroppedAttributesCount int `json:"droppedAttributesCount,omitempty"`
+	DroppedEventsCount     int `json:"droppedEventsCount,omitempty"`
+	DroppedLinksCount      int `json:"droppedLinksCount,omitempty"`
+}
+
+type otlpEvent struct {
+	TimeUnixNano string         `json:"timeUnixNano"`
+	Name         string         `json:"name"`
+	Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+
+	DroppedAttributesCount int `json:"droppedAttributesCount,omitempty"`
+}
+
+type otlpLink struct {
+	TraceID    string         `json:"traceId"`
+	SpanID     string         `json:"spanId"`
+	Attributes []otlpKeyValue `json:"attributes,omitempty"`
+
+	DroppedAttributesCount int `json:"droppedAttributesCount,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpLogRecord struct {
+	TimeUnixNano         string                 `json:"timeUnixNano"`
+	ObservedTimeUnixNano string                 `json:"observedTimeUnixNano"`
+	SeverityNumber       int                    `json:"severityNumber"`
+	SeverityText         string                 `json:"severityText"`
+	Body                 map[string]interface{} `json:"body"`
+	Attributes           []otlpKeyValue         `json:"attributes,omitempty"`
+	TraceID              string                 `json:"traceId,omitempty"`
+	SpanID               string                 `json:"spanId,omitempty"`
+}
+
+// OTLP enum values
+const (
+	otlpStatusOK         = 1
+	otlpStatusError      = 2
+	otlpTemporalityDelta = 1
+)
+
+var otlpSpanKind = map[SpanKind]int{
+	SpanKindInternal: 1,
+	SpanKindServer:   2,
+	SpanKindClient:   3,
+	SpanKindProducer: 4,
+	SpanKindConsumer: 5,
+}
+
+var otlpSeverity = map[LogLevel]int{
+	LogLevelDebug: 5,
+	LogLevelInfo:  9,
+	LogLevelWarn:  13,
+	LogLevelError: 17,
+	LogLevelFatal: 21,
+}
+
+func otlpScope() map[string]interface{} {
+	return map[string]interface{}{"name": "github.com/masbenx/omnipulse-go", "version": Version}
+}
+
+func otlpSpanFrom(s SpanData) otlpSpan {
+	out := otlpSpan{
+		TraceID:           s.TraceID,
+		SpanID:            s.SpanID,
+		ParentSpanID:      s.ParentSpanID,
+		Name:              s.Name,
+		Kind:              otlpSpanKind[SpanKindInternal],
+		StartTimeUnixNano: otlpTime(s.StartTime),
+		EndTimeUnixNano:   otlpTime(s.EndTime),
+		Attributes:        otlpAttributes(s.Attributes),
+		Status:            otlpStatus{Code: otlpStatusOK},
+
+		DroppedAttributesCount: s.DroppedAttributesCount,
+		DroppedEventsCount:     s.DroppedEventsCount,
+		DroppedLinksCount:      s.DroppedLinksCount,
+	}
+	if kind, ok := otlpSpanKind[s.Kind]; ok {
+		out.Kind = kind
+	}
+	if s.Status == SpanStatusError {
+		out.Status = otlpStatus{Code: otlpStatusError, Message: s.StatusMessage}
+	}
+	for _, ev := range s.Events {
+		out.Events = append(out.Events, otlpEvent{
+			TimeUnixNano: otlpTime(ev.Timestamp),
+			Name:         ev.Name,
+			Attributes:   otlpAttributes(ev.Attributes),
+
+			DroppedAttributesCount: ev.DroppedAttributesCount,
+		})
+	}
+	for _, l := range s.Links {
+		out.Links = append(out.Links, otlpLink{
+			TraceID:    l.TraceID,
+			SpanID:     l.SpanID,
+			Attributes: otlpAttributes(l.Attributes),
+
+			DroppedAttributesCount: l.DroppedAttributesCount,
+		})
+	}
+	return out
+}
+
+func otlpLogRecordFrom(l LogEntry) otlpLogRecord {
+	attrs := otlpAttributes(l.Tags)
+	if l.Host != "" {
+		attrs = append(attrs, otlpKeyValue{Key: "host.name", Value: otlpValue(l.Host)})
+	}
+	return otlpLogRecord{
+		TimeUnixNano:         otlpTime(l.Timestamp),
+		ObservedTimeUnixNano: otlpTime(l.Timestamp),
+		SeverityNumber:       otlpSeverity[l.Level],
+		SeverityText:         strings.ToUpper(string(l.Level)),
+		Body:                 otlpValue(l.Message),
+		Attributes:           attrs,
+		TraceID:              l.TraceID,
+		SpanID:               l.SpanID,
+	}
+}
+
+func otlpJobRecordFrom(j JobData) otlpLogRecord {
+	ts, err := time.Parse(time.RFC3339, j.Ts)
+	if err != nil {
+		ts = time.Now()
+	}
+	level := LogLevelInfo
+	if j.Error != "" {
+		level = LogLevelError
+	}
+	return otlpLogRecord{
+		TimeUnixNano:         otlpTime(ts),
+		ObservedTimeUnixNano: otlpTime(ts),
+		SeverityNumber:       otlpSeverity[level],
+		SeverityText:         strings.ToUpper(string(level)),
+		Body:                 otlpValue(fmt.Sprintf("job %s %s", j.JobName, j.Status)),
+		Attributes: otlpAttributes(map[string]interface{}{
+			"job.name":         j.JobName,
+			"job.queue":        j.Queue,
+			"job.status":       j.Status,
+			"job.error":        j.Error,
+			"job.duration_ms":  j.DurationMs,
+			"job.wait_time_ms": j.WaitTimeMs,
+		}),
+	}
+}
+
+func otlpMetricFor(m MetricData) map[string]interface{} {
+	out := map[string]interface{}{"name": m.Name}
+	switch m.Type {
+	case MetricTypeCounter:
+		out["sum"] = map[string]interface{}{
+			"aggregationTemporality": otlpTemporalityDelta,
+			"isMonotonic":            false,
+			"dataPoints":             []map[string]interface{}{},
+		}
+	case MetricTypeHistogram:
+		out["histogram"] = map[string]interface{}{
+			"aggregationTemporality": otlpTemporalityDelta,
+			"dataPoints":             []map[string]interface{}{},
+		}
+	default:
+		out["gauge"] = map[string]interface{}{
+			"dataPoints": []map[string]interface{}{},
+		}
+	}
+	return out
+}
+
+func appendOTLPDataPoint(metric map[string]interface{}, m MetricData) {
+	attrs := make(map[string]interface{}, len(m.Tags)+len(m.Dimensions))
+	for k, v := range m.Dimensions {
+		attrs[k] = v
+	}
+	for k, v := range m.Tags {
+		attrs[k] = v
+	}
+
+	point := map[string]interface{}{
+		"timeUnixNano": otlpTime(m.Timestamp),
+		"attributes":   otlpAttributes(attrs),
+	}
+
+	var data map[string]interface{}
+	switch {
+	case metric["sum"] != nil:
+		data = metric["sum"].(map[string]interface{})
+		point["asDouble"] = m.Value
+	case metric["histogram"] != nil:
+		data = metric["histogram"].(map[string]interface{})
+		point["count"] = "1"
+		point["sum"] = m.Value
+		point["min"] = m.Value
+		point["max"] = m.Value
+		point["bucketCounts"] = []string{"1"}
+		point["explicitBounds"] = []float64{}
+	default:
+		data = metric["gauge"].(map[string]interface{})
+		point["asDouble"] = m.Value
+	}
+	data["dataPoints"] = append(data["dataPoints"].([]map[string]interface{}), point)
+}
+
+func otlpTime(t time.Time) string {
+	if t.IsZero() {
+		return "0"
+	}
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+func otlpAttributes(attrs map[string]interface{}) []otlpKeyValue {
+	if len(attrs) == 0 {
+		return nil
+	}
+	out := make([]otlpKeyValue, 0, len(attrs))
+	for k, v := range attrs {
+		out = append(out, otlpKeyValue{Key: k, Value: otlpValue(v)})
+	}
+	return out
+}
+
+// otlpValue converts a Go value to an OTLP AnyValue
+func otlpValue(v interface{}) map[string]interface{} {
+	switch val := v.(type) {
+	case string:
+		return map[string]interface{}{"stringValue": val}
+	case bool:
+		return map[string]interface{}{"boolValue": val}
+	case int:
+		return map[string]interface{}{"intValue": strconv.FormatInt(int64(val), 10)}
+	case int8:
+		return map[string]interface{}{"intValue": strconv.FormatInt(int64(val), 10)}
+	case int16:
+		return map[string]interface{}{"intValue": strconv.FormatInt(int64(val), 10)}
+	case int32:
+		return map[string]interface{}{"intValue": strconv.FormatInt(int64(val), 10)}
+	case int64:
+		return map[string]interface{}{"intValue": strconv.FormatInt(val, 10)}
+	case uint:
+		return map[string]interface{}{"intValue": strconv.FormatUint(uint64(val), 10)}
+	case uint8:
+		return map[string]interface{}{"intValue": strconv.FormatUint(uint64(val), 10)}
+	case uint16:
+		return map[string]interface{}{"intValue": strconv.FormatUint(uint64(val), 10)}
+	case uint32:
+		return map[string]interface{}{"intValue": strconv.FormatUint(uint64(val), 10)}
+	case uint64:
+		return map[string]interface{}{"intValue": strconv.FormatUint(val, 10)}
+	case float32:
+		return map[string]interface{}{"doubleValue": float64(val)}
+	case float64:
+		return map[string]interface{}{"doubleValue": val}
+	case []string:
+		values := make([]map[string]interface{}, len(val))
+		for i, s := range val {
+			values[i] = otlpValue(s)
+		}
+		return map[string]interface{}{"arrayValue": map[string]interface{}{"values": values}}
+	case []bool:
+		values := make([]map[string]interface{}, len(val))
+		for i, b := range val {
+			values[i] = otlpValue(b)
+		}
+		return map[string]interface{}{"arrayValue": map[string]interface{}{"values": values}}
+	case []int64:
+		values := make([]map[string]interface{}, len(val))
+		for i, n := range val {
+			values[i] = otlpValue(n)
+		}
+		return map[string]interface{}{"arrayValue": map[string]interface{}{"values": values}}
+	case []float64:
+		values := make([]map[string]interface{}, len(val))
+		for i, f := range val {
+			values[i] = otlpValue(f)
+		}
+		return map[string]interface{}{"arrayValue": map[string]interface{}{"values": values}}
+	case []interface{}:
+		values := make([]map[string]interface{}, len(val))
+		for i, s := range val {
+			values[i] = otlpValue(s)
+		}
+		return map[string]interface{}{"arrayValue": map[string]interface{}{"values": values}}
+	case map[string]interface{}:
+		return map[string]interface{}{"kvlistValue": map[string]interface{}{"values": otlpAttributes(val)}}
+	case nil:
+		return map[string]interface{}{}
+	case error:
+		return map[string]interface{}{"stringValue": val.Error()}
+	case fmt.Stringer:
+		return map[string]interface{}{"stringValue": val.String()}
+	}
+	return map[string]interface{}{"stringValue": fmt.Sprint(v)}
+}
@@ -0,0 +1,215 @@
+package omnipulse
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// truncationMarker is appended to string values shortened to fit MaxPayloadBytes
+const truncationMarker = "...[truncated]"
+
+// defaultMaxPayloadBytes is the default limit on the uncompressed JSON body of one request
+const defaultMaxPayloadBytes = 1 << 20
+
+// payloadOverhead leaves room for the {"key":[...]} wrapper around encoded items
+const payloadOverhead = 64
+
+// sendItems encodes items one by one, packs them into requests of at most e.maxPayload
+// bytes and sends them in order. Items too large on their own are truncated; items that
+// cannot be encoded, or that the backend rejects as too large on their own, are dropped
+// and the rest are still sent. A *PartialExportError reports dropped items, and how many
+// leading items were handled when a request fails after earlier ones succeeded.
+func sendItems[T any](ctx context.Context, e *HTTPExporter, endpoint, key string, items []T, truncate func(T, int) T) error {
+	limit := e.maxPayload - payloadOverhead
+	var chunk []json.RawMessage
+	var indexes []int // position in items of each chunk entry
+	size := 0
+	var rej rejections
+
+	flush := func() error {
+		handled, err := e.sendChunk(ctx, endpoint, key, chunk, indexes, &rej)
+		if err != nil {
+			// Items after the failed request are retried, including any skipped among them
+			cut := indexes[handled]
+			rejected := rej.before(cut)
+			return partialExport(cut-rejected, rejected, err)
+		}
+		chunk, indexes, size = nil, nil, 0
+		return nil
+	}
+
+	for i, item := range items {
+		data, err := encodeItem(item, limit, truncate)
+		if err != nil {
+			rej.add(i, fmt.Errorf("failed to encode item: %w", err))
+			continue
+		}
+		if len(chunk) > 0 && size+len(data)+1 > limit {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		chunk = append(chunk, data)
+		indexes = append(indexes, i)
+		size += len(data) + 1
+	}
+
+	if len(chunk) > 0 {
+		if err := flush(); err != nil {
+			return err
+		}
+	}
+	if n := len(rej.indexes); n > 0 {
+		return &PartialExportError{Delivered: len(items) - n, Rejected: n, Err: rej.err}
+	}
+	return nil
+}
+
+// rejections records the items of a batch that were dropped
+type rejections struct {
+	indexes []int // positions in the batch, in ascending order
+	err     error // the last rejection
+}
+
+func (r *rejections) add(index int, err error) {
+	r.indexes = append(r.indexes, index)
+	r.err = err
+}
+
+// before returns the number of items dropped before position cut
+func (r *rejections) before(cut int) int {
+	n := 0
+	for _, i := range r.indexes {
+		if i < cut {
+			n++
+		}
+	}
+	return n
+}
+
+// sendChunk sends encoded items as one request, bisecting and resending when the backend
+// rejects the body as too large. An item rejected on its own is dropped and recorded in
+// rej by its position in indexes. It returns how many leading items were delivered or
+// dropped.
+func (e *HTTPExporter) sendChunk(ctx context.Context, endpoint, key string, items []json.RawMessage, indexes []int, rej *rejections) (int, error) {
+	var buf bytes.Buffer
+	buf.WriteString(`{"`)
+	buf.WriteString(key)
+	buf.WriteString(`":[`)
+	for i, item := range items {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.Write(item)
+	}
+	buf.WriteString(`]}`)
+
+	err := e.sendRaw(ctx, endpoint, buf.Bytes())
+
+	var se *SendError
+	if errors.As(err, &se) && se.StatusCode == http.StatusRequestEntityTooLarge {
+		if len(items) == 1 {
+			rej.add(indexes[0], err)
+			return 1, nil
+		}
+		mid := len(items) / 2
+		if handled, err := e.sendChunk(ctx, endpoint, key, items[:mid], indexes[:mid], rej); err != nil {
+			return handled, err
+		}
+		handled, err := e.sendChunk(ctx, endpoint, key, items[mid:], indexes[mid:], rej)
+		return mid + handled, err
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(items), nil
+}
+
+// encodeItem marshals item, truncating its string values with decreasing limits until the
+// encoding fits in max bytes
+func encodeItem[T any](item T, max int, truncate func(T, int) T) (json.RawMessage, error) {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return nil, err
+	}
+	for valueLimit := max / 2; len(data) > max && valueLimit >= len(truncationMarker); valueLimit /= 2 {
+		if data, err = json.Marshal(truncate(item, valueLimit)); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// truncateString shortens s to limit bytes including the truncation marker, without
+// splitting a UTF-8 sequence
+func truncateString(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	return truncateAttribute(s, max(limit-len(truncationMarker), 0)) + truncationMarker
+}
+
+// truncateValues copies m with every string value shortened to limit bytes
+func truncateValues(m map[string]interface{}, limit int) map[string]interface{} {
+	if m == nil {
+		return nil
+	}
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		if s, ok := v.(string); ok {
+			v = truncateString(s, limit)
+		}
+		out[k] = v
+	}
+	return out
+}
+
+func truncateLogEntry(e LogEntry, limit int) LogEntry {
+	e.Message = truncateString(e.Message, limit)
+	e.Tags = truncateValues(e.Tags, limit)
+	return e
+}
+
+func truncateSpanData(s SpanData, limit int) SpanData {
+	s.StatusMessage = truncateString(s.StatusMessage, limit)
+	s.Attributes = truncateValues(s.Attributes, limit)
+	// Event count shrinks with the limit so spans with thousands of events still fit
+	n := min(len(s.Events), max(limit/64, 1))
+	events := make([]SpanEvent, n)
+	for i, ev := range s.Events[:n] {
+		ev.Attributes = truncateValues(ev.Attributes, limit)
+		events[i] = ev
+	}
+	s.DroppedEventsCount += len(s.Events) - n
+	s.Events = events
+	if s.Links != nil {
+		links := make([]SpanLink, len(s.Links))
+		for i, l := range s.Links {
+			l.Attributes = truncateValues(l.Attributes, limit)
+			links[i] = l
+		}
+		s.Links = links
+	}
+	return s
+}
+
+func truncateMetricData(m MetricData, limit int) MetricData {
+	if m.Tags != nil {
+		tags := make(map[string]string, len(m.Tags))
+		for k, v := range m.Tags {
+			tags[k] = truncateString(v, limit)
+		}
+		m.Tags = tags
+	}
+	m.Dimensions = truncateValues(m.Dimensions, limit)
+	return m
+}
+
+func truncateJobData(j JobData, limit int) JobData {
+	j.Error = truncateString(j.Error, limit)
+	return j
+}
@@ -0,0 +1,320 @@
+package omnipulse
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/masbenx/omnipulse-go/semconv"
+)
+
+// SpanProcessor observes, changes and drops spans as they start and end. Processors are
+// registered with Config.SpanProcessors and run in order.
+type SpanProcessor interface {
+	// OnStart is called with the context passed to Tracer.Start (context.Background() for
+	// StartSpan) for every span the sampler did not drop. It may set attributes.
+	OnStart(ctx context.Context, span *Span)
+	// OnEnd is called with every finished span the sampler did not drop, as changed by the
+	// processors before it. It returns the span to pass on, and false to drop it. It must
+	// not modify the span's maps or slices in place; return a changed copy instead. Spans
+	// passed on may still be dropped by tail sampling.
+	OnEnd(span SpanData) (SpanData, bool)
+}
+
+// processSpan runs a finished span through Config.SpanProcessors, then exports it
+func (c *Client) processSpan(span SpanData, localRoot bool) {
+	for _, p := range c.config.SpanProcessors {
+		var keep bool
+		if span, keep = p.OnEnd(span); !keep {
+			return
+		}
+	}
+	c.exportSpan(span, localRoot)
+}
+
+// shutdownProcessors shuts down the span processors that hold resources
+func (c *Client) shutdownProcessors(ctx context.Context) {
+	for _, p := range c.config.SpanProcessors {
+		if s, ok := p.(interface{ Shutdown(context.Context) error }); ok {
+			if err := s.Shutdown(ctx); err != nil {
+				c.reportError(err, "span processor shutdown failed")
+			}
+		}
+	}
+}
+
+// EnrichAttributes sets attrs on every span when it starts. Attributes already set by the
+// caller are kept.
+func EnrichAttributes(attrs map[string]interface{}) SpanProcessor {
+	return enrichProcessor{attrs: attrs}
+}
+
+type enrichProcessor struct {
+	attrs map[string]interface{}
+}
+
+func (p enrichProcessor) OnStart(_ context.Context, span *Span) {
+	span.mu.Lock()
+	defer span.mu.Unlock()
+	for k, v := range p.attrs {
+		if _, ok := span.Attributes[k]; !ok {
+			span.setAttributeLocked(k, v)
+		}
+	}
+}
+
+func (p enrichProcessor) OnEnd(span SpanData) (SpanData, bool) {
+	return span, true
+}
+
+// FilterSpans drops spans for which keep returns false
+func FilterSpans(keep func(span SpanData) bool) SpanProcessor {
+	return filterProcessor{keep: keep}
+}
+
+// DropSpansNamed drops spans with any of the given names
+func DropSpansNamed(names ...string) SpanProcessor {
+	set := stringSet(names)
+	return FilterSpans(func(span SpanData) bool {
+		return !set[span.Name]
+	})
+}
+
+// DropRoutes drops server spans whose http.route or http.target attribute is one of
+// routes, e.g. health and metrics endpoints
+func DropRoutes(routes ...string) SpanProcessor {
+	set := stringSet(routes)
+	return FilterSpans(func(span SpanData) bool {
+		route, _ := span.Attributes[semconv.HTTPRoute].(string)
+		target, _ := span.Attributes[semconv.HTTPTarget].(string)
+		return !set[route] && !set[target]
+	})
+}
+
+type filterProcessor struct {
+	keep func(span SpanData) bool
+}
+
+func (p filterProcessor) OnStart(context.Context, *Span) {}
+
+func (p filterProcessor) OnEnd(span SpanData) (SpanData, bool) {
+	return span, p.keep(span)
+}
+
+// TransformSpans calls fn to change each finished span, e.g. to rename it. fn receives a
+// copy of the span's attributes that it may modify.
+func TransformSpans(fn func(span *SpanData)) SpanProcessor {
+	return transformProcessor{fn: fn}
+}
+
+// RedactAttributes replaces the values of the given attribute keys, on spans and their
+// events, with "[REDACTED]"
+func RedactAttributes(keys ...string) SpanProcessor {
+	set := stringSet(keys)
+	redact := func(attrs map[string]interface{}) map[string]interface{} {
+		var out map[string]interface{}
+		for k := range attrs {
+			if !set[k] {
+				continue
+			}
+			if out == nil {
+				out = make(map[string]interface{}, len(attrs))
+				for k, v := range attrs {
+					out[k] = v
+				}
+			}
+			out[k] = redactedValue
+		}
+		if out == nil {
+			return attrs
+		}
+		return out
+	}
+	return TransformSpans(func(span *SpanData) {
+		for k := range set {
+			if _, ok := span.Attributes[k]; ok {
+				span.Attributes[k] = redactedValue
+			}
+		}
+		for i, ev := range span.Events {
+			span.Events[i].Attributes = redact(ev.Attributes)
+		}
+	})
+}
+
+// redactedValue replaces the values removed by RedactAttributes
+const redactedValue = "[REDACTED]"
+
+type transformProcessor struct {
+	fn func(span *SpanData)
+}
+
+func (p transformProcessor) OnStart(context.Context, *Span) {}
+
+func (p transformProcessor) OnEnd(span SpanData) (SpanData, bool) {
+	attrs := make(map[string]interface{}, len(span.Attributes))
+	for k, v := range span.Attributes {
+		attrs[k] = v
+	}
+	span.Attributes = attrs
+	span.Events = append([]SpanEvent(nil), span.Events...)
+	span.Links = append([]SpanLink(nil), span.Links...)
+	p.fn(&span)
+	return span, true
+}
+
+func stringSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[v] = true
+	}
+	return set
+}
+
+// BatchSpanProcessorConfig configures a BatchSpanProcessor
+type BatchSpanProcessorConfig struct {
+	// MaxBatchSize is the most spans passed to one ExportSpans call (default: 512)
+	MaxBatchSize int
+	// MaxQueueSize caps the spans waiting to be exported; further spans are dropped (default: 2048)
+	MaxQueueSize int
+	// Interval is how often queued spans are exported (default: 5s)
+	Interval time.Duration
+	// ExportTimeout bounds each ExportSpans call (default: 30s)
+	ExportTimeout time.Duration
+	// ErrorHandler receives export errors and reports of dropped spans (default: ignored)
+	ErrorHandler func(error)
+}
+
+// BatchSpanProcessor sends a copy of every span it sees to another Exporter in batches,
+// independently of the client's own buffers. The client shuts it down, flushing queued
+// spans and shutting down its exporter, when it closes.
+type BatchSpanProcessor struct {
+	exporter Exporter
+	config   BatchSpanProcessorConfig
+
+	mu      sync.Mutex
+	queue   []SpanData
+	dropped int
+	closed  bool
+
+	kick     chan struct{}
+	done     chan struct{}
+	stopped  chan struct{}
+	stopOnce sync.Once
+}
+
+// NewBatchSpanProcessor creates a BatchSpanProcessor exporting to exporter
+func NewBatchSpanProcessor(exporter Exporter, cfg BatchSpanProcessorConfig) *BatchSpanProcessor {
+	if cfg.MaxBatchSize <= 0 {
+		cfg.MaxBatchSize = 512
+	}
+	if cfg.MaxQueueSize <= 0 {
+		cfg.MaxQueueSize = 2048
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = 5 * time.Second
+	}
+	if cfg.ExportTimeout <= 0 {
+		cfg.ExportTimeout = 30 * time.Second
+	}
+
+	p := &BatchSpanProcessor{
+		exporter: exporter,
+		config:   cfg,
+		kick:     make(chan struct{}, 1),
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	go p.run()
+	return p
+}
+
+func (p *BatchSpanProcessor) OnStart(context.Context, *Span) {}
+
+// OnEnd queues a copy of span for export and passes span on unchanged
+func (p *BatchSpanProcessor) OnEnd(span SpanData) (SpanData, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed || len(p.queue) >= p.config.MaxQueueSize {
+		p.dropped++
+		return span, true
+	}
+	p.queue = append(p.queue, span)
+	if len(p.queue) >= p.config.MaxBatchSize {
+		select {
+		case p.kick <- struct{}{}:
+		default:
+		}
+	}
+	return span, true
+}
+
+// Shutdown exports the queued spans and shuts down the exporter
+func (p *BatchSpanProcessor) Shutdown(ctx context.Context) error {
+	p.stopOnce.Do(func() {
+		p.mu.Lock()
+		p.closed = true
+		p.mu.Unlock()
+		close(p.done)
+	})
+
+	select {
+	case <-p.stopped:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return p.exporter.Shutdown(ctx)
+}
+
+func (p *BatchSpanProcessor) run() {
+	defer close(p.stopped)
+
+	ticker := time.NewTicker(p.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.export()
+		case <-p.kick:
+			p.export()
+		case <-p.done:
+			p.export()
+			return
+		}
+	}
+}
+
+// export sends every queued span in batches of at most MaxBatchSize
+func (p *BatchSpanProcessor) export() {
+	for {
+		p.mu.Lock()
+		n := min(len(p.queue), p.config.MaxBatchSize)
+		batch := p.queue[:n:n]
+		p.queue = p.queue[n:]
+		dropped := p.dropped
+		p.dropped = 0
+		p.mu.Unlock()
+
+		if dropped > 0 {
+			p.handleError(fmt.Errorf("batch span processor queue full, dropped %d spans", dropped))
+		}
+		if n == 0 {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), p.config.ExportTimeout)
+		err := p.exporter.ExportSpans(ctx, batch)
+		cancel()
+		if err != nil {
+			p.handleError(err)
+		}
+	}
+}
+
+func (p *BatchSpanProcessor) handleError(err error) {
+	if p.config.ErrorHandler != nil {
+		p.config.ErrorHandler(err)
+	}
+}
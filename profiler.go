@@ -3,18 +3,14 @@ package omnipulse
 import (
 	"bytes"
 	"fmt"
-	"io"
-	"mime/multipart"
-	"net/http"
 	"os"
 	"runtime/pprof"
-	"strconv"
 	"time"
 )
 
 func (c *Client) startProfiler() {
 	defer c.wg.Done()
-	
+
 	// Collect profile every 60 seconds
 	ticker := time.NewTicker(60 * time.Second)
 	defer ticker.Stop()
@@ -27,9 +23,7 @@ func (c *Client) startProfiler() {
 	// Start initial profile
 	err := pprof.StartCPUProfile(&buf)
 	if err != nil {
-		if c.config.Debug {
-			fmt.Printf("[omnipulse] failed to start CPU profiler: %v\n", err)
-		}
+		c.reportError(err, "failed to start CPU profiler")
 		return // Cannot profile
 	}
 
@@ -41,16 +35,14 @@ func (c *Client) startProfiler() {
 			// Send collected profile buffer
 			profileData := make([]byte, buf.Len())
 			copy(profileData, buf.Bytes())
-			
+
 			go c.sendProfile(profileData, instanceHash, "cpu", 60)
 
 			// Reset buffer and restart
 			buf.Reset()
 			err := pprof.StartCPUProfile(&buf)
 			if err != nil {
-				if c.config.Debug {
-					fmt.Printf("[omnipulse] failed to restart CPU profiler: %v\n", err)
-				}
+				c.reportError(err, "failed to restart CPU profiler")
 				return
 			}
 
@@ -66,42 +58,18 @@ func (c *Client) sendProfile(data []byte, instanceHash, profileType string, dura
 		return
 	}
 
-	body := &bytes.Buffer{}
-	writer := multipart.NewWriter(body)
-
-	part, err := writer.CreateFormFile("profile", "cpu.pb.gz")
-	if err == nil {
-		part.Write(data)
-	}
-
-	writer.WriteField("instance_hash", instanceHash)
-	writer.WriteField("env", c.config.Environment)
-	writer.WriteField("profile_type", profileType)
-	writer.WriteField("duration_seconds", strconv.Itoa(durationSecs))
-	writer.Close()
-
-	req, err := http.NewRequestWithContext(c.ctx, "POST", c.config.APIUrl+"/api/ingest/app-profiles", body)
-	if err != nil {
-		return
+	profile := Profile{
+		Data:            data,
+		InstanceHash:    instanceHash,
+		Type:            profileType,
+		DurationSeconds: durationSecs,
 	}
-
-	req.Header.Set("Content-Type", writer.FormDataContentType())
-	req.Header.Set("X-Ingest-Key", c.config.IngestKey)
-	req.Header.Set("User-Agent", fmt.Sprintf("omnipulse-go-sdk/%s", Version))
-
-	resp, err := c.httpClient.Do(req)
+	ctx := withBytesCounter(c.ctx, &c.stats.bytesSent)
+	_, err := c.withRetry(ctx, "profile", func() error {
+		return c.exporter.ExportProfile(ctx, profile)
+	})
 	if err != nil {
-		if c.config.Debug {
-			fmt.Printf("[omnipulse] failed to send profile: %v\n", err)
-		}
-		return
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode >= 400 {
-		if c.config.Debug {
-			b, _ := io.ReadAll(resp.Body)
-			fmt.Printf("[omnipulse] backend rejected profile (status %d): %s\n", resp.StatusCode, string(b))
-		}
+		c.stats.recordError(err)
+		c.reportError(err, "failed to send profile", "profile_type", profileType)
 	}
 }
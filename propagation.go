@@ -0,0 +1,249 @@
+package omnipulse
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// SpanContext is the part of a span that crosses process boundaries
+type SpanContext struct {
+	TraceID    string
+	SpanID     string
+	Sampled    bool
+	TraceState string
+	// Remote is set on span contexts extracted from incoming requests
+	Remote bool
+}
+
+// IsValid reports whether sc carries a trace ID
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != ""
+}
+
+// Carrier is the header set a Propagator reads from and writes to
+type Carrier interface {
+	Get(key string) string
+	Set(key, value string)
+	Keys() []string
+}
+
+// HeaderCarrier adapts http.Header to Carrier
+type HeaderCarrier http.Header
+
+func (h HeaderCarrier) Get(key string) string {
+	return http.Header(h).Get(key)
+}
+
+func (h HeaderCarrier) Set(key, value string) {
+	http.Header(h).Set(key, value)
+}
+
+func (h HeaderCarrier) Keys() []string {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// Propagator reads and writes span contexts in request headers
+type Propagator interface {
+	// Extract returns the span context found in carrier, or an invalid SpanContext
+	Extract(carrier Carrier) SpanContext
+	// Inject writes sc into carrier
+	Inject(sc SpanContext, carrier Carrier)
+	// Fields lists the headers the propagator uses
+	Fields() []string
+}
+
+// defaultPropagator is used when Config.Propagator is nil
+func defaultPropagator() Propagator {
+	return CompositePropagator(W3CPropagator{}, OmniPulsePropagator{})
+}
+
+// W3CPropagator implements the W3C Trace Context traceparent and tracestate headers
+type W3CPropagator struct{}
+
+const (
+	traceparentHeader = "traceparent"
+	tracestateHeader  = "tracestate"
+)
+
+func (W3CPropagator) Extract(carrier Carrier) SpanContext {
+	parts := strings.Split(strings.TrimSpace(carrier.Get(traceparentHeader)), "-")
+	if len(parts) < 4 {
+		return SpanContext{}
+	}
+	version, traceID, spanID, flags := parts[0], parts[1], parts[2], parts[3]
+	// Version 00 has exactly four fields; later versions may append more
+	if len(version) != 2 || !isHex(version) || version == "ff" || (version == "00" && len(parts) != 4) {
+		return SpanContext{}
+	}
+	if len(traceID) != 32 || !isHex(traceID) || isZeroID(traceID) {
+		return SpanContext{}
+	}
+	if len(spanID) != 16 || !isHex(spanID) || isZeroID(spanID) {
+		return SpanContext{}
+	}
+	if len(flags) != 2 || !isHex(flags) {
+		return SpanContext{}
+	}
+
+	return SpanContext{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		Sampled:    hexDigit(flags[1])&1 == 1,
+		TraceState: carrier.Get(tracestateHeader),
+		Remote:     true,
+	}
+}
+
+func (W3CPropagator) Inject(sc SpanContext, carrier Carrier) {
+	// IDs that are not W3C-shaped (e.g. from the legacy headers) cannot be expressed
+	if !isHexID(sc.TraceID, 32) || !isHexID(sc.SpanID, 16) {
+		return
+	}
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	carrier.Set(traceparentHeader, fmt.Sprintf("00-%s-%s-%s", sc.TraceID, sc.SpanID, flags))
+	if sc.TraceState != "" {
+		carrier.Set(tracestateHeader, sc.TraceState)
+	}
+}
+
+func (W3CPropagator) Fields() []string {
+	return []string{traceparentHeader, tracestateHeader}
+}
+
+// OmniPulsePropagator implements the legacy X-OmniPulse-Trace-ID and X-OmniPulse-Span-ID
+// headers. Spans extracted from them are always sampled. IDs must be hex and not all
+// zeros; shorter IDs are left-padded with zeros.
+type OmniPulsePropagator struct{}
+
+const (
+	omnipulseTraceHeader = "X-OmniPulse-Trace-ID"
+	omnipulseSpanHeader  = "X-OmniPulse-Span-ID"
+)
+
+func (OmniPulsePropagator) Extract(carrier Carrier) SpanContext {
+	traceID, ok := normalizeID(carrier.Get(omnipulseTraceHeader), 32)
+	if !ok {
+		return SpanContext{}
+	}
+	// A malformed span ID still lets the trace continue, as a root of this process
+	spanID, _ := normalizeID(carrier.Get(omnipulseSpanHeader), 16)
+	return SpanContext{
+		TraceID: traceID,
+		SpanID:  spanID,
+		Sampled: true,
+		Remote:  true,
+	}
+}
+
+func (OmniPulsePropagator) Inject(sc SpanContext, carrier Carrier) {
+	if !sc.IsValid() {
+		return
+	}
+	carrier.Set(omnipulseTraceHeader, sc.TraceID)
+	if sc.SpanID != "" {
+		carrier.Set(omnipulseSpanHeader, sc.SpanID)
+	}
+}
+
+func (OmniPulsePropagator) Fields() []string {
+	return []string{omnipulseTraceHeader, omnipulseSpanHeader}
+}
+
+// compositePropagator combines several propagators
+type compositePropagator struct {
+	propagators []Propagator
+}
+
+// CompositePropagator returns a Propagator that injects with all of the given propagators
+// and extracts with the first one that finds a valid span context
+func CompositePropagator(propagators ...Propagator) Propagator {
+	return &compositePropagator{propagators: propagators}
+}
+
+func (p *compositePropagator) Extract(carrier Carrier) SpanContext {
+	for _, prop := range p.propagators {
+		if sc := prop.Extract(carrier); sc.IsValid() {
+			return sc
+		}
+	}
+	return SpanContext{}
+}
+
+func (p *compositePropagator) Inject(sc SpanContext, carrier Carrier) {
+	for _, prop := range p.propagators {
+		prop.Inject(sc, carrier)
+	}
+}
+
+func (p *compositePropagator) Fields() []string {
+	var fields []string
+	for _, prop := range p.propagators {
+		fields = append(fields, prop.Fields()...)
+	}
+	return fields
+}
+
+// Propagator returns the client's configured propagator
+func (c *Client) Propagator() Propagator {
+	return c.config.Propagator
+}
+
+// Inject writes the context of the span in ctx into carrier
+func (c *Client) Inject(ctx context.Context, carrier Carrier) {
+	if span := SpanFromContext(ctx); span != nil {
+		c.config.Propagator.Inject(span.SpanContext(), carrier)
+	}
+}
+
+// Extract reads a remote span context from carrier
+func (c *Client) Extract(carrier Carrier) SpanContext {
+	return c.config.Propagator.Extract(carrier)
+}
+
+func isHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if hexDigit(s[i]) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// hexDigit returns the value of a lowercase hex digit, or -1
+func hexDigit(b byte) int {
+	switch {
+	case b >= '0' && b <= '9':
+		return int(b - '0')
+	case b >= 'a' && b <= 'f':
+		return int(b-'a') + 10
+	}
+	return -1
+}
+
+func isZeroID(s string) bool {
+	return strings.Trim(s, "0") == ""
+}
+
+// normalizeID lowercases id and left-pads it with zeros to size hex digits. It reports false
+// for IDs that are empty, longer than size, not hex or all zeros.
+func normalizeID(id string, size int) (string, bool) {
+	id = strings.ToLower(id)
+	if id == "" || len(id) > size || !isHex(id) || isZeroID(id) {
+		return "", false
+	}
+	return strings.Repeat("0", size-len(id)) + id, true
+}
+
+// isHexID reports whether id can be written as-is by formats that require size hex digits
+func isHexID(id string, size int) bool {
+	return len(id) == size && isHex(id) && !isZeroID(id)
+}
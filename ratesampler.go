@@ -0,0 +1,149 @@
+package omnipulse
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// samplingProbabilityKey is the span attribute holding the probability a span was sampled
+// with, so the backend can extrapolate counts
+const samplingProbabilityKey = "sampling.probability"
+
+// rateWindow is the period over which observed span rates are measured
+const rateWindow = time.Second
+
+type rateLimitingSampler struct {
+	perSecond float64
+	burst     float64
+	now       func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+// tokenBucket tracks the spans allowed for one service
+type tokenBucket struct {
+	tokens      float64
+	last        time.Time
+	windowStart time.Time
+	seen, kept  int
+}
+
+// RateLimitingSampler returns a Sampler that samples at most perSecond new spans per
+// second for each service, with bursts of up to one second's worth or one span, whichever
+// is more. Spans carry a
+// sampling.probability attribute with the fraction of spans kept so far in the current
+// second. Spans over the limit are still recorded and exported if they end with an error,
+// with sampling.probability set to 1.
+func RateLimitingSampler(perSecond float64) Sampler {
+	burst := perSecond
+	if perSecond > 0 {
+		// Rates below one span per second still need room for a whole token
+		burst = math.Max(perSecond, 1)
+	}
+	return &rateLimitingSampler{
+		perSecond: perSecond,
+		burst:     burst,
+		now:       time.Now,
+		buckets:   make(map[string]*tokenBucket),
+	}
+}
+
+func (s *rateLimitingSampler) ShouldSample(p SamplingParameters) SamplingResult {
+	now := s.now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.buckets[p.ServiceName]
+	if b == nil {
+		b = &tokenBucket{tokens: s.burst, last: now, windowStart: now}
+		s.buckets[p.ServiceName] = b
+	}
+
+	if now.Sub(b.windowStart) >= rateWindow {
+		b.windowStart, b.seen, b.kept = now, 0, 0
+	}
+	b.tokens = math.Min(s.burst, b.tokens+now.Sub(b.last).Seconds()*s.perSecond)
+	b.last = now
+	b.seen++
+
+	decision := RecordOnly
+	if b.tokens >= 1 {
+		b.tokens--
+		b.kept++
+		decision = RecordAndSample
+	}
+	return SamplingResult{
+		Decision:    decision,
+		Attributes:  map[string]interface{}{samplingProbabilityKey: float64(b.kept) / float64(b.seen)},
+		KeepOnError: decision == RecordOnly,
+	}
+}
+
+func (s *rateLimitingSampler) Description() string {
+	return fmt.Sprintf("RateLimitingSampler{%g}", s.perSecond)
+}
+
+type adaptiveSampler struct {
+	target float64
+	min    float64
+	now    func() time.Time
+
+	mu          sync.Mutex
+	windowStart time.Time
+	seen        int
+	rate        float64
+	probability float64
+}
+
+// AdaptiveSampler returns a Sampler that adjusts its sampling probability every second to
+// export about targetPerSecond spans, never going below minProbability. Spans that are
+// not sampled are still recorded and exported if they end with an error, with
+// sampling.probability set to 1.
+func AdaptiveSampler(targetPerSecond, minProbability float64) Sampler {
+	return &adaptiveSampler{
+		target:      targetPerSecond,
+		min:         math.Max(0, math.Min(1, minProbability)),
+		now:         time.Now,
+		probability: 1,
+	}
+}
+
+func (s *adaptiveSampler) ShouldSample(p SamplingParameters) SamplingResult {
+	now := s.now()
+
+	s.mu.Lock()
+	if s.windowStart.IsZero() {
+		s.windowStart = now
+	}
+	if elapsed := now.Sub(s.windowStart); elapsed >= rateWindow {
+		observed := float64(s.seen) / elapsed.Seconds()
+		// Smooth the rate so one quiet or busy second does not swing the probability
+		if s.rate == 0 {
+			s.rate = observed
+		} else {
+			s.rate = 0.5*s.rate + 0.5*observed
+		}
+		s.probability = 1
+		if s.rate > s.target {
+			s.probability = math.Max(s.min, s.target/s.rate)
+		}
+		s.windowStart, s.seen = now, 0
+	}
+	s.seen++
+	probability := s.probability
+	s.mu.Unlock()
+
+	attrs := map[string]interface{}{samplingProbabilityKey: probability}
+	if probability >= 1 || traceIDValue(p.TraceID)>>1 < uint64(probability*(1<<63)) {
+		return SamplingResult{Decision: RecordAndSample, Attributes: attrs}
+	}
+	return SamplingResult{Decision: RecordOnly, Attributes: attrs, KeepOnError: true}
+}
+
+func (s *adaptiveSampler) Description() string {
+	return fmt.Sprintf("AdaptiveSampler{target:%g,min:%g}", s.target, s.min)
+}
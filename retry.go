@@ -0,0 +1,149 @@
+package omnipulse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryConfig controls how failed requests to the backend are retried
+type RetryConfig struct {
+	// MaxAttempts is the total number of attempts per request, including the first (default: 3)
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry (default: 500ms)
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between attempts, including Retry-After (default: 30s)
+	MaxBackoff time.Duration
+	// Jitter is the fraction of each delay that is randomized, between 0 and 1.
+	// Use a negative value to disable jitter (default: 0.2)
+	Jitter float64
+}
+
+// SendError describes a request to the backend that failed
+type SendError struct {
+	// StatusCode is the HTTP status returned by the backend, or 0 if no response was received
+	StatusCode int
+	// RetryAfter is the delay requested by the backend on 429 and 503 responses
+	RetryAfter time.Duration
+	// Err is the underlying transport error, if any
+	Err error
+}
+
+func (e *SendError) Error() string {
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("server returned status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("failed to send request: %v", e.Err)
+}
+
+func (e *SendError) Unwrap() error {
+	return e.Err
+}
+
+// Temporary reports whether the request may succeed if retried.
+// Network errors, 408, 429 and 5xx responses are temporary; other 4xx responses are permanent.
+func (e *SendError) Temporary() bool {
+	switch {
+	case e.StatusCode == 0:
+		return true
+	case e.StatusCode == http.StatusRequestTimeout, e.StatusCode == http.StatusTooManyRequests:
+		return true
+	case e.StatusCode >= 500:
+		return true
+	}
+	return false
+}
+
+// isRetryable reports whether err is a temporary send failure
+func isRetryable(err error) bool {
+	var se *SendError
+	if errors.As(err, &se) {
+		return se.Temporary()
+	}
+	return false
+}
+
+// newStatusError builds a SendError from a failed response, honouring Retry-After on 429 and 503
+func newStatusError(resp *http.Response) *SendError {
+	se := &SendError{StatusCode: resp.StatusCode}
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		se.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	}
+	return se
+}
+
+// parseRetryAfter parses a Retry-After header given either as delay-seconds or as an HTTP date
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(value); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := t.Sub(now); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// backoff returns the delay to wait after the given failed attempt (starting at 1)
+func (r RetryConfig) backoff(attempt int) time.Duration {
+	d := r.InitialBackoff
+	for i := 1; i < attempt && d < r.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > r.MaxBackoff {
+		d = r.MaxBackoff
+	}
+	if r.Jitter > 0 {
+		delta := float64(d) * r.Jitter
+		d = time.Duration(float64(d) - delta + rand.Float64()*2*delta)
+	}
+	return d
+}
+
+// withRetry calls fn until it succeeds, fails permanently, runs out of attempts or ctx is done.
+// It returns the number of times fn was called.
+func (c *Client) withRetry(ctx context.Context, what string, fn func() error) (int, error) {
+	policy := c.config.Retry
+	for attempt := 1; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return attempt - 1, &SendError{Err: err}
+		}
+		err := fn()
+		if err == nil || !isRetryable(err) || attempt >= policy.MaxAttempts || ctx.Err() != nil {
+			return attempt, err
+		}
+
+		delay := policy.backoff(attempt)
+		var se *SendError
+		if errors.As(err, &se) && se.RetryAfter > 0 {
+			delay = min(se.RetryAfter, policy.MaxBackoff)
+		}
+		// A client that is shutting down retries at once rather than holding up Close
+		if c.ctx.Err() != nil {
+			delay = 0
+		}
+
+		c.diag(slog.LevelWarn, "send failed, retrying",
+			"what", what, "attempt", attempt, "max_attempts", policy.MaxAttempts, "delay", delay, "error", err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return attempt, err
+		}
+	}
+}
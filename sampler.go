@@ -0,0 +1,161 @@
+package omnipulse
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"math"
+)
+
+// SamplingDecision is a sampler's verdict on a new span
+type SamplingDecision int
+
+const (
+	// Drop creates a non-recording span that is never exported
+	Drop SamplingDecision = iota
+	// RecordOnly records the span but does not export it
+	RecordOnly
+	// RecordAndSample records and exports the span
+	RecordAndSample
+)
+
+func (d SamplingDecision) String() string {
+	switch d {
+	case Drop:
+		return "drop"
+	case RecordOnly:
+		return "record_only"
+	case RecordAndSample:
+		return "record_and_sample"
+	}
+	return fmt.Sprintf("sampling_decision(%d)", int(d))
+}
+
+// SamplingParameters describes the span being sampled
+type SamplingParameters struct {
+	// ParentContext is the parent span's context; invalid for root spans
+	ParentContext SpanContext
+	// ParentKeepOnError is set when the local parent is recorded but not sampled and is
+	// exported only if it ends with an error
+	ParentKeepOnError bool
+	TraceID           string
+	Name              string
+	ServiceName       string
+	Attributes        map[string]interface{}
+	Links             []SpanLink
+}
+
+// SamplingResult is returned by Sampler.ShouldSample
+type SamplingResult struct {
+	Decision SamplingDecision
+	// Attributes are added to the span
+	Attributes map[string]interface{}
+	// KeepOnError exports a RecordOnly span if it ends with SpanStatusError
+	KeepOnError bool
+}
+
+// Sampler decides which spans are recorded and exported. It is called once per span from
+// StartSpan and must be safe for concurrent use.
+type Sampler interface {
+	ShouldSample(p SamplingParameters) SamplingResult
+	Description() string
+}
+
+type alwaysSampler struct{}
+
+// AlwaysSample returns a Sampler that samples every span
+func AlwaysSample() Sampler {
+	return alwaysSampler{}
+}
+
+func (alwaysSampler) ShouldSample(SamplingParameters) SamplingResult {
+	return SamplingResult{Decision: RecordAndSample}
+}
+
+func (alwaysSampler) Description() string {
+	return "AlwaysOnSampler"
+}
+
+type neverSampler struct{}
+
+// NeverSample returns a Sampler that drops every span
+func NeverSample() Sampler {
+	return neverSampler{}
+}
+
+func (neverSampler) ShouldSample(SamplingParameters) SamplingResult {
+	return SamplingResult{Decision: Drop}
+}
+
+func (neverSampler) Description() string {
+	return "AlwaysOffSampler"
+}
+
+type traceIDRatioSampler struct {
+	fraction float64
+	bound    uint64
+}
+
+// TraceIDRatioBased returns a Sampler that samples the given fraction of traces. The
+// decision is derived from the trace ID, so every service using the same fraction keeps
+// or drops the same traces.
+func TraceIDRatioBased(fraction float64) Sampler {
+	fraction = math.Max(0, math.Min(1, fraction))
+	return &traceIDRatioSampler{
+		fraction: fraction,
+		bound:    uint64(fraction * (1 << 63)),
+	}
+}
+
+func (s *traceIDRatioSampler) ShouldSample(p SamplingParameters) SamplingResult {
+	if s.fraction >= 1 || traceIDValue(p.TraceID)>>1 < s.bound {
+		return SamplingResult{Decision: RecordAndSample}
+	}
+	return SamplingResult{Decision: Drop}
+}
+
+func (s *traceIDRatioSampler) Description() string {
+	return fmt.Sprintf("TraceIDRatioBased{%g}", s.fraction)
+}
+
+// traceIDValue maps a trace ID to a uniformly distributed uint64: the low 8 bytes of a hex
+// ID, or a hash of IDs in other formats
+func traceIDValue(traceID string) uint64 {
+	if len(traceID) >= 16 {
+		if b, err := hex.DecodeString(traceID[len(traceID)-16:]); err == nil {
+			return binary.BigEndian.Uint64(b)
+		}
+	}
+	h := fnv.New64a()
+	h.Write([]byte(traceID))
+	return h.Sum64()
+}
+
+type parentBasedSampler struct {
+	root Sampler
+}
+
+// ParentBased returns a Sampler that follows the parent span's sampled flag and uses root
+// for spans without a parent. Children of a local parent that is kept only on error are
+// kept on error too.
+func ParentBased(root Sampler) Sampler {
+	return &parentBasedSampler{root: root}
+}
+
+func (s *parentBasedSampler) ShouldSample(p SamplingParameters) SamplingResult {
+	if !p.ParentContext.IsValid() {
+		return s.root.ShouldSample(p)
+	}
+	if p.ParentContext.Sampled {
+		return SamplingResult{Decision: RecordAndSample}
+	}
+	if p.ParentKeepOnError {
+		return SamplingResult{Decision: RecordOnly, KeepOnError: true}
+	}
+	return SamplingResult{Decision: Drop}
+}
+
+func (s *parentBasedSampler) Description() string {
+	return fmt.Sprintf("ParentBased{root:%s}", s.root.Description())
+}
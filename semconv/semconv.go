@@ -0,0 +1,72 @@
+// Package semconv defines span attribute keys following the OpenTelemetry semantic
+// conventions, so spans from the OmniPulse middlewares and from application code use the
+// same names.
+package semconv
+
+// General attributes
+const (
+	Error        = "error"
+	ErrorMessage = "error.message"
+	ErrorType    = "error.type"
+)
+
+// Exception event attributes, recorded by Span.RecordError
+const (
+	ExceptionEventName  = "exception"
+	ExceptionType       = "exception.type"
+	ExceptionMessage    = "exception.message"
+	ExceptionStacktrace = "exception.stacktrace"
+	// ExceptionChain lists the errors reached through errors.Unwrap, outermost first
+	ExceptionChain = "exception.chain"
+)
+
+// HTTP attributes
+const (
+	HTTPMethod       = "http.method"
+	HTTPURL          = "http.url"
+	HTTPHost         = "http.host"
+	HTTPRoute        = "http.route"
+	HTTPTarget       = "http.target"
+	HTTPScheme       = "http.scheme"
+	HTTPUserAgent    = "http.user_agent"
+	HTTPRemoteAddr   = "http.remote_addr"
+	HTTPStatusCode   = "http.status_code"
+	HTTPRequestSize  = "http.request_size"
+	HTTPResponseSize = "http.response_size"
+	HTTPResendCount  = "http.resend_count"
+)
+
+// Database attributes
+const (
+	DBSystem    = "db.system"
+	DBName      = "db.name"
+	DBUser      = "db.user"
+	DBStatement = "db.statement"
+	DBOperation = "db.operation"
+	DBTable     = "db.sql.table"
+)
+
+// Messaging attributes
+const (
+	MessagingSystem            = "messaging.system"
+	MessagingDestination       = "messaging.destination.name"
+	MessagingOperation         = "messaging.operation"
+	MessagingMessageID         = "messaging.message.id"
+	MessagingConversationID    = "messaging.message.conversation_id"
+	MessagingBatchMessageCount = "messaging.batch.message_count"
+	MessagingConsumerGroup     = "messaging.consumer.group.name"
+)
+
+// RPC attributes
+const (
+	RPCSystem         = "rpc.system"
+	RPCService        = "rpc.service"
+	RPCMethod         = "rpc.method"
+	RPCGRPCStatusCode = "rpc.grpc.status_code"
+)
+
+// Network attributes
+const (
+	NetPeerName = "net.peer.name"
+	NetPeerPort = "net.peer.port"
+)
@@ -0,0 +1,279 @@
+package omnipulse
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// errClientClosed is returned by Flush once the client has been closed
+var errClientClosed = errors.New("omnipulse: client is closed")
+
+// batch is a chunk of buffered items of one signal waiting to be exported
+type batch struct {
+	ctx    context.Context
+	signal Signal
+	items  interface{} // []LogEntry, []SpanData, []MetricData or []JobData
+	count  int
+	done   chan batchResult
+}
+
+type batchResult struct {
+	err  error
+	lost int // items neither delivered nor spooled
+}
+
+// flushCall tracks one flush shared by every caller that joined it
+type flushCall struct {
+	ctx     context.Context
+	done    chan struct{}
+	err     error
+	dropped map[Signal]int
+}
+
+// startSenders starts one sender per signal. Each sender exports its signal's batches
+// strictly in order, so at most numSignals exports run at once.
+func (c *Client) startSenders() {
+	for sig := range c.queues {
+		c.queues[sig] = make(chan *batch, c.config.SenderQueueSize)
+		c.senderWg.Add(1)
+		go c.sender(c.queues[sig])
+	}
+}
+
+// stopSenders rejects new flushes, then closes the queues once the running flush loop has
+// exited and waits for queued batches to be exported. If ctx ends first the senders are
+// left to finish in the background and ctx's error is returned.
+func (c *Client) stopSenders(ctx context.Context) error {
+	c.flushMu.Lock()
+	c.closed = true
+	idle := c.flushIdle
+	c.flushMu.Unlock()
+
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		<-idle
+		for _, q := range c.queues {
+			close(q)
+		}
+		c.senderWg.Wait()
+		if c.spool != nil {
+			_ = c.spool.close()
+		}
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *Client) sender(queue chan *batch) {
+	defer c.senderWg.Done()
+	for b := range queue {
+		b.done <- c.exportBatch(b)
+	}
+}
+
+// exportBatch sends a batch with retries, spooling it if it still fails temporarily
+func (c *Client) exportBatch(b *batch) batchResult {
+	var rejected int
+	var err error
+	switch items := b.items.(type) {
+	case []LogEntry:
+		rejected, err = exportItems(b, items, c.sendLogs)
+	case []SpanData:
+		rejected, err = exportItems(b, items, c.sendSpans)
+	case []MetricData:
+		rejected, err = exportItems(b, items, c.sendMetrics)
+	case []JobData:
+		rejected, err = exportItems(b, items, c.sendJobs)
+	}
+	if err == nil {
+		return batchResult{}
+	}
+
+	c.reportError(err, "failed to send batch", "signal", b.signal.String(), "items", b.count+rejected)
+	res := batchResult{err: err, lost: rejected}
+	if b.count > 0 && !c.saveBatch(b, err) {
+		res.lost += b.count
+	}
+	return res
+}
+
+// exportItems sends a batch's items and narrows the batch to those that were neither
+// delivered nor rejected. It returns the number of rejected items.
+func exportItems[T any](b *batch, items []T, send func(context.Context, []T) ([]T, int, error)) (int, error) {
+	rest, rejected, err := send(b.ctx, items)
+	if err != nil {
+		b.items, b.count = rest, len(rest)
+	}
+	return rejected, err
+}
+
+// saveBatch spools a batch that failed temporarily and reports whether it was kept
+func (c *Client) saveBatch(b *batch, err error) bool {
+	if c.spool != nil && isRetryable(err) && c.spoolBatch(b.signal, b.items) {
+		c.stats.spooled[b.signal].Add(uint64(b.count))
+		return true
+	}
+	c.stats.failed[b.signal].Add(uint64(b.count))
+	return false
+}
+
+// sendBatch exports items of one signal with retries, recording delivery stats. After a
+// *PartialExportError only the items that were neither delivered nor rejected are retried;
+// on failure they are returned with the error. It also returns the number of rejected
+// items, which are counted as failed.
+func sendBatch[T any](c *Client, ctx context.Context, sig Signal, items []T, export func(context.Context, []T) error) ([]T, int, error) {
+	ctx = withBytesCounter(ctx, &c.stats.bytesSent)
+	start := time.Now()
+	rejected := 0
+	var rejectErr error
+	attempts, err := c.withRetry(ctx, sig.String(), func() error {
+		err := export(ctx, items)
+		var pe *PartialExportError
+		if errors.As(err, &pe) {
+			delivered := min(pe.Delivered, len(items))
+			dropped := min(pe.Rejected, len(items)-delivered)
+			c.stats.sent[sig].Add(uint64(delivered))
+			c.stats.failed[sig].Add(uint64(dropped))
+			if dropped > 0 {
+				rejected += dropped
+				rejectErr = err
+			}
+			items = items[delivered+dropped:]
+			if len(items) == 0 {
+				return nil
+			}
+		}
+		return err
+	})
+	c.stats.recordSend(sig, len(items), attempts, time.Since(start), err)
+	if err == nil && rejectErr != nil {
+		// Report rejected items even when the rest was delivered on a later attempt
+		err = rejectErr
+		c.stats.recordError(err)
+	}
+	return items, rejected, err
+}
+
+// flushCoalesced runs a flush, or joins the follow-up flush if one is already running, so
+// that triggers arriving during a flush collapse into a single extra flush. The follow-up
+// flush runs with the context of the caller that requested it.
+func (c *Client) flushCoalesced(ctx context.Context) (map[Signal]int, error) {
+	c.flushMu.Lock()
+	if c.closed {
+		c.flushMu.Unlock()
+		return nil, errClientClosed
+	}
+	if c.flushing != nil {
+		if c.pending == nil {
+			c.pending = &flushCall{ctx: ctx, done: make(chan struct{})}
+		}
+		call := c.pending
+		c.flushMu.Unlock()
+		select {
+		case <-call.done:
+			return call.dropped, call.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	call := &flushCall{ctx: ctx, done: make(chan struct{})}
+	c.flushing = call
+	c.flushIdle = make(chan struct{})
+	c.flushMu.Unlock()
+
+	first := call
+	for call != nil {
+		call.dropped, call.err = c.flushOnce(call.ctx)
+		close(call.done)
+
+		c.flushMu.Lock()
+		call = c.pending
+		c.pending = nil
+		// A follow-up requested before the client closed must not touch the closing queues
+		if call != nil && c.closed {
+			call.err = errClientClosed
+			close(call.done)
+			call = nil
+		}
+		c.flushing = call
+		if call == nil {
+			close(c.flushIdle)
+		}
+		c.flushMu.Unlock()
+	}
+	return first.dropped, first.err
+}
+
+// flushOnce drains the buffers, queues them as BatchSize chunks and waits for the senders.
+// It returns the number of items per signal that were neither delivered nor spooled.
+func (c *Client) flushOnce(ctx context.Context) (map[Signal]int, error) {
+	c.bufferMu.Lock()
+	logs := c.logBuffer
+	spans := c.spanBuffer
+	metrics := c.metricBuffer
+	jobs := c.jobBuffer
+	c.logBuffer = make([]LogEntry, 0, c.config.BatchSize)
+	c.spanBuffer = make([]SpanData, 0, c.config.BatchSize)
+	c.metricBuffer = make([]MetricData, 0, c.config.BatchSize)
+	c.jobBuffer = make([]JobData, 0, c.config.BatchSize)
+	c.bufferBytes = [numSignals]int{}
+	close(c.spaceCh)
+	c.spaceCh = make(chan struct{})
+	c.bufferMu.Unlock()
+
+	var batches []*batch
+	batches = appendBatches(ctx, batches, SignalLogs, logs, c.config.BatchSize)
+	batches = appendBatches(ctx, batches, SignalSpans, spans, c.config.BatchSize)
+	batches = appendBatches(ctx, batches, SignalMetrics, metrics, c.config.BatchSize)
+	batches = appendBatches(ctx, batches, SignalJobs, jobs, c.config.BatchSize)
+
+	var lastErr error
+	dropped := make(map[Signal]int)
+
+	// Senders run concurrently, so queue everything before waiting on any result
+	var queued []*batch
+	for _, b := range batches {
+		select {
+		case c.queues[b.signal] <- b:
+			queued = append(queued, b)
+			continue
+		case <-ctx.Done():
+		}
+		lastErr = ctx.Err()
+		if !c.saveBatch(b, &SendError{Err: lastErr}) {
+			dropped[b.signal] += b.count
+		}
+	}
+
+	for _, b := range queued {
+		res := <-b.done
+		if res.err != nil {
+			lastErr = res.err
+		}
+		dropped[b.signal] += res.lost
+	}
+
+	// The backend is reachable again, so deliver what was spooled while it was not
+	if lastErr == nil {
+		_ = c.replaySpool(ctx)
+	}
+
+	return dropped, lastErr
+}
+
+// appendBatches splits items into chunks of at most size and appends them as batches
+func appendBatches[T any](ctx context.Context, batches []*batch, sig Signal, items []T, size int) []*batch {
+	for len(items) > 0 {
+		n := min(size, len(items))
+		batches = append(batches, &batch{ctx: ctx, signal: sig, items: items[:n:n], count: n, done: make(chan batchResult, 1)})
+		items = items[n:]
+	}
+	return batches
+}
@@ -0,0 +1,389 @@
+package omnipulse
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Spool record framing: magic, payload length, CRC-32 of the payload, then the payload
+// itself. The payload is one byte of Signal followed by the JSON-encoded batch. The magic
+// lets the reader resynchronize after a torn or corrupted record.
+const (
+	spoolMagic        uint32 = 0x4f505350 // "OPSP"
+	spoolHeaderSize          = 12
+	spoolSegmentBytes        = 4 << 20
+	spoolSegmentExt          = ".seg"
+	spoolMaxRecord           = 64 << 20
+)
+
+// spool persists batches that could not be delivered as append-only segment files
+type spool struct {
+	dir          string
+	maxBytes     int64
+	maxAge       time.Duration
+	segmentBytes int64
+
+	mu       sync.Mutex
+	cur      *os.File
+	curSeq   uint64
+	curSize  int64
+	nextSeq  uint64
+	records  int
+	bytes    int64
+	replayMu sync.Mutex
+}
+
+type spoolRecord struct {
+	signal  Signal
+	payload []byte
+}
+
+func openSpool(dir string, maxBytes int64, maxAge time.Duration) (*spool, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create spool directory: %w", err)
+	}
+
+	s := &spool{
+		dir:          dir,
+		maxBytes:     maxBytes,
+		maxAge:       maxAge,
+		segmentBytes: spoolSegmentBytes,
+	}
+
+	segs, err := s.segments()
+	if err != nil {
+		return nil, err
+	}
+	for _, seq := range segs {
+		recs, size := s.readSegment(seq)
+		s.records += len(recs)
+		s.bytes += size
+		s.nextSeq = seq + 1
+	}
+
+	return s, nil
+}
+
+// append writes a batch to the current segment, rotating and evicting as needed
+func (s *spool) append(sig Signal, data []byte) error {
+	payload := make([]byte, 0, len(data)+1)
+	payload = append(payload, byte(sig))
+	payload = append(payload, data...)
+	frame := encodeSpoolRecord(payload)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cur != nil && s.curSize+int64(len(frame)) > s.segmentBytes {
+		s.sealLocked()
+	}
+	if s.cur == nil {
+		f, err := os.OpenFile(s.segmentPath(s.nextSeq), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return fmt.Errorf("failed to open spool segment: %w", err)
+		}
+		s.cur = f
+		s.curSeq = s.nextSeq
+		s.curSize = 0
+		s.nextSeq++
+	}
+
+	if _, err := s.cur.Write(frame); err != nil {
+		return fmt.Errorf("failed to write spool segment: %w", err)
+	}
+	s.curSize += int64(len(frame))
+	s.records++
+	s.bytes += int64(len(frame))
+
+	s.evictLocked()
+	return nil
+}
+
+// replay hands every spooled batch to send in order. Segments are removed once all of their
+// records are delivered or permanently rejected; on the first temporary failure the remaining
+// records are kept for the next replay. If send returns a non-nil payload with the failure,
+// it replaces the failed record.
+func (s *spool) replay(send func(sig Signal, payload []byte) ([]byte, error)) error {
+	s.replayMu.Lock()
+	defer s.replayMu.Unlock()
+
+	s.mu.Lock()
+	s.sealLocked()
+	limit := s.nextSeq
+	s.mu.Unlock()
+
+	segs, err := s.segments()
+	if err != nil {
+		return err
+	}
+
+	for _, seq := range segs {
+		if seq >= limit {
+			break // written after the replay started
+		}
+		path := s.segmentPath(seq)
+		recs, size := s.readSegment(seq)
+
+		if s.expired(path) {
+			s.remove(path, len(recs), size)
+			continue
+		}
+
+		for i, rec := range recs {
+			rest, err := send(rec.signal, rec.payload)
+			if err != nil && isRetryable(err) {
+				if rest != nil {
+					recs[i].payload = rest
+				}
+				s.rewrite(seq, recs[i:], len(recs), size)
+				return err
+			}
+		}
+		s.remove(path, len(recs), size)
+	}
+
+	return nil
+}
+
+// depth returns the number of spooled batches and their size on disk
+func (s *spool) depth() (int, int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.records, s.bytes
+}
+
+func (s *spool) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sealLocked()
+	return nil
+}
+
+func (s *spool) sealLocked() {
+	if s.cur != nil {
+		_ = s.cur.Close()
+		s.cur = nil
+	}
+}
+
+// evictLocked drops the oldest sealed segments until the spool fits within maxBytes
+func (s *spool) evictLocked() {
+	if s.maxBytes <= 0 || s.bytes <= s.maxBytes {
+		return
+	}
+	segs, err := s.segments()
+	if err != nil {
+		return
+	}
+	for _, seq := range segs {
+		if s.bytes <= s.maxBytes {
+			return
+		}
+		if s.cur != nil && seq == s.curSeq {
+			continue
+		}
+		recs, size := s.readSegment(seq)
+		if err := os.Remove(s.segmentPath(seq)); err == nil {
+			s.records -= len(recs)
+			s.bytes -= size
+		}
+	}
+}
+
+func (s *spool) expired(path string) bool {
+	if s.maxAge <= 0 {
+		return false
+	}
+	info, err := os.Stat(path)
+	return err == nil && time.Since(info.ModTime()) > s.maxAge
+}
+
+func (s *spool) remove(path string, records int, size int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.Remove(path); err == nil {
+		s.records -= records
+		s.bytes -= size
+	}
+}
+
+// rewrite replaces a segment with the records that are still pending
+func (s *spool) rewrite(seq uint64, pending []spoolRecord, records int, size int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := s.segmentPath(seq)
+	if _, err := os.Stat(path); err != nil {
+		return // evicted while replaying
+	}
+
+	var buf bytes.Buffer
+	for _, rec := range pending {
+		payload := append([]byte{byte(rec.signal)}, rec.payload...)
+		buf.Write(encodeSpoolRecord(payload))
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o644); err != nil {
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return
+	}
+	s.records += len(pending) - records
+	s.bytes += int64(buf.Len()) - size
+}
+
+// segments lists segment sequence numbers in ascending order
+func (s *spool) segments() ([]uint64, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spool directory: %w", err)
+	}
+	var segs []uint64
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, spoolSegmentExt) {
+			continue
+		}
+		var seq uint64
+		if _, err := fmt.Sscanf(strings.TrimSuffix(name, spoolSegmentExt), "%d", &seq); err != nil {
+			continue
+		}
+		segs = append(segs, seq)
+	}
+	sort.Slice(segs, func(i, j int) bool { return segs[i] < segs[j] })
+	return segs, nil
+}
+
+func (s *spool) segmentPath(seq uint64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%020d%s", seq, spoolSegmentExt))
+}
+
+// readSegment returns the valid records in a segment and the segment's size on disk
+func (s *spool) readSegment(seq uint64) ([]spoolRecord, int64) {
+	data, err := os.ReadFile(s.segmentPath(seq))
+	if err != nil {
+		return nil, 0
+	}
+	return decodeSpoolRecords(data), int64(len(data))
+}
+
+func encodeSpoolRecord(payload []byte) []byte {
+	frame := make([]byte, spoolHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(frame[0:4], spoolMagic)
+	binary.BigEndian.PutUint32(frame[4:8], uint32(len(payload)))
+	binary.BigEndian.PutUint32(frame[8:12], crc32.ChecksumIEEE(payload))
+	copy(frame[spoolHeaderSize:], payload)
+	return frame
+}
+
+// decodeSpoolRecords parses framed records, skipping over corrupted regions by scanning
+// forward to the next magic marker
+func decodeSpoolRecords(data []byte) []spoolRecord {
+	var recs []spoolRecord
+	off := 0
+	for off+spoolHeaderSize <= len(data) {
+		if binary.BigEndian.Uint32(data[off:]) != spoolMagic {
+			off++
+			continue
+		}
+		n := int(binary.BigEndian.Uint32(data[off+4:]))
+		sum := binary.BigEndian.Uint32(data[off+8:])
+		end := off + spoolHeaderSize + n
+		if n < 1 || n > spoolMaxRecord || end > len(data) {
+			off++
+			continue
+		}
+		payload := data[off+spoolHeaderSize : end]
+		if crc32.ChecksumIEEE(payload) != sum {
+			off++
+			continue
+		}
+		recs = append(recs, spoolRecord{signal: Signal(payload[0]), payload: payload[1:]})
+		off = end
+	}
+	return recs
+}
+
+// spoolBatch persists a batch that failed with a temporary error and reports whether it was stored
+func (c *Client) spoolBatch(sig Signal, batch interface{}) bool {
+	if c.spool == nil {
+		return false
+	}
+	data, err := json.Marshal(batch)
+	if err == nil {
+		err = c.spool.append(sig, data)
+	}
+	if err != nil {
+		c.reportError(err, "failed to spool batch", "signal", sig.String())
+		return false
+	}
+	return true
+}
+
+// replaySpool re-sends spooled batches in the order they were written
+func (c *Client) replaySpool(ctx context.Context) error {
+	if c.spool == nil {
+		return nil
+	}
+	if n, _ := c.spool.depth(); n == 0 {
+		return nil
+	}
+	err := c.spool.replay(func(sig Signal, payload []byte) ([]byte, error) {
+		return c.sendSpooled(ctx, sig, payload)
+	})
+	if err != nil {
+		c.diag(slog.LevelWarn, "spool replay stopped", "error", err)
+	}
+	return err
+}
+
+// sendSpooled re-sends one spooled batch. After a partial failure it returns the encoded
+// items that are still undelivered.
+func (c *Client) sendSpooled(ctx context.Context, sig Signal, payload []byte) ([]byte, error) {
+	var rest []byte
+	var err error
+	switch sig {
+	case SignalLogs:
+		rest, err = resendSpooled(ctx, payload, c.sendLogs)
+	case SignalSpans:
+		rest, err = resendSpooled(ctx, payload, c.sendSpans)
+	case SignalMetrics:
+		rest, err = resendSpooled(ctx, payload, c.sendMetrics)
+	case SignalJobs:
+		rest, err = resendSpooled(ctx, payload, c.sendJobs)
+	default:
+		err = fmt.Errorf("unknown spooled signal %d", sig)
+	}
+	if err != nil && !isRetryable(err) {
+		c.reportError(err, "dropping spooled batch", "signal", sig.String())
+	}
+	return rest, err
+}
+
+func resendSpooled[T any](ctx context.Context, payload []byte, send func(context.Context, []T) ([]T, int, error)) ([]byte, error) {
+	var items []T
+	if err := json.Unmarshal(payload, &items); err != nil {
+		return nil, err
+	}
+	rest, _, err := send(ctx, items)
+	if err != nil && len(rest) < len(items) {
+		if data, mErr := json.Marshal(rest); mErr == nil {
+			return data, err
+		}
+	}
+	return nil, err
+}
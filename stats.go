@@ -0,0 +1,165 @@
+package omnipulse
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SignalStats holds delivery counters for one signal since the client was created
+type SignalStats struct {
+	// Enqueued is the number of items accepted into the buffer
+	Enqueued uint64
+	// Sent is the number of items delivered to the exporter
+	Sent uint64
+	// Dropped is the number of items discarded because the buffer was full
+	Dropped uint64
+	// Failed is the number of items that could not be delivered and were not spooled
+	Failed uint64
+	// Spooled is the number of items written to the on-disk spool
+	Spooled uint64
+	// Retried is the number of items re-sent after a temporary failure
+	Retried uint64
+	// BufferDepth is the number of items currently buffered
+	BufferDepth int
+}
+
+// Stats is a point-in-time snapshot of the client's delivery health
+type Stats struct {
+	Signals map[Signal]SignalStats
+	// BytesSent is the number of request body bytes written by the built-in exporters
+	BytesSent uint64
+	// LastError is the most recent send failure, if any
+	LastError error
+	// LastErrorTime is when LastError occurred
+	LastErrorTime time.Time
+	// LastSendLatency is the duration of the most recent export, including retries
+	LastSendLatency time.Duration
+	// AvgSendLatency is the mean duration of all exports, including retries
+	AvgSendLatency time.Duration
+	// SpoolBatches and SpoolBytes describe the on-disk spool
+	SpoolBatches int
+	SpoolBytes   int64
+}
+
+// clientStats holds the counters behind Stats
+type clientStats struct {
+	enqueued [numSignals]atomic.Uint64
+	sent     [numSignals]atomic.Uint64
+	failed   [numSignals]atomic.Uint64
+	spooled  [numSignals]atomic.Uint64
+	retried  [numSignals]atomic.Uint64
+
+	bytesSent    atomic.Uint64
+	sends        atomic.Int64
+	latencyTotal atomic.Int64
+	latencyLast  atomic.Int64
+
+	errMu       sync.Mutex
+	lastErr     error
+	lastErrTime time.Time
+}
+
+func (s *clientStats) recordSend(sig Signal, n, attempts int, latency time.Duration, err error) {
+	if attempts > 1 {
+		s.retried[sig].Add(uint64(n * (attempts - 1)))
+	}
+	if attempts > 0 {
+		s.sends.Add(1)
+		s.latencyTotal.Add(int64(latency))
+		s.latencyLast.Store(int64(latency))
+	}
+	if err == nil {
+		s.sent[sig].Add(uint64(n))
+		return
+	}
+	s.recordError(err)
+}
+
+func (s *clientStats) recordError(err error) {
+	s.errMu.Lock()
+	s.lastErr = err
+	s.lastErrTime = time.Now()
+	s.errMu.Unlock()
+}
+
+// Stats returns a snapshot of delivery counters, buffer depths and spool usage
+func (c *Client) Stats() Stats {
+	st := Stats{
+		Signals:         make(map[Signal]SignalStats, numSignals),
+		BytesSent:       c.stats.bytesSent.Load(),
+		LastSendLatency: time.Duration(c.stats.latencyLast.Load()),
+	}
+	if n := c.stats.sends.Load(); n > 0 {
+		st.AvgSendLatency = time.Duration(c.stats.latencyTotal.Load() / n)
+	}
+
+	c.stats.errMu.Lock()
+	st.LastError = c.stats.lastErr
+	st.LastErrorTime = c.stats.lastErrTime
+	c.stats.errMu.Unlock()
+
+	c.bufferMu.Lock()
+	depths := [numSignals]int{len(c.logBuffer), len(c.spanBuffer), len(c.metricBuffer), len(c.jobBuffer)}
+	c.bufferMu.Unlock()
+
+	for sig := Signal(0); sig < numSignals; sig++ {
+		st.Signals[sig] = SignalStats{
+			Enqueued:    c.stats.enqueued[sig].Load(),
+			Sent:        c.stats.sent[sig].Load(),
+			Dropped:     c.dropped[sig].Load(),
+			Failed:      c.stats.failed[sig].Load(),
+			Spooled:     c.stats.spooled[sig].Load(),
+			Retried:     c.stats.retried[sig].Load(),
+			BufferDepth: depths[sig],
+		}
+	}
+
+	st.SpoolBatches, st.SpoolBytes = c.SpoolDepth()
+	return st
+}
+
+// emitSelfTelemetry records the client's own counters as omnipulse.sdk.* gauges. They are
+// dropped rather than waited for when the metric buffer is full, so a backed-up client
+// does not stall the flush worker on its own telemetry.
+func (c *Client) emitSelfTelemetry() {
+	st := c.Stats()
+	now := time.Now()
+	gauge := func(name string, value float64, tags map[string]string) {
+		c.addMetricNoWait(MetricData{
+			Name:        name,
+			Type:        MetricTypeGauge,
+			Value:       value,
+			Timestamp:   now,
+			ServiceName: c.config.ServiceName,
+			Tags:        tags,
+		})
+	}
+	for sig, s := range st.Signals {
+		tags := map[string]string{"signal": sig.String()}
+		gauge("omnipulse.sdk.items.enqueued", float64(s.Enqueued), tags)
+		gauge("omnipulse.sdk.items.sent", float64(s.Sent), tags)
+		gauge("omnipulse.sdk.items.dropped", float64(s.Dropped), tags)
+		gauge("omnipulse.sdk.items.failed", float64(s.Failed), tags)
+		gauge("omnipulse.sdk.items.retried", float64(s.Retried), tags)
+		gauge("omnipulse.sdk.buffer.depth", float64(s.BufferDepth), tags)
+	}
+	gauge("omnipulse.sdk.bytes.sent", float64(st.BytesSent), nil)
+	gauge("omnipulse.sdk.send.latency", float64(st.LastSendLatency.Milliseconds()), nil)
+	gauge("omnipulse.sdk.spool.batches", float64(st.SpoolBatches), nil)
+}
+
+// bytesSentKey carries the client's byte counter to the built-in exporters
+type bytesSentKey struct{}
+
+func withBytesCounter(ctx context.Context, n *atomic.Uint64) context.Context {
+	return context.WithValue(ctx, bytesSentKey{}, n)
+}
+
+// recordBytesSent adds n to the byte counter in ctx, if any
+func recordBytesSent(ctx context.Context, n int) {
+	if counter, ok := ctx.Value(bytesSentKey{}).(*atomic.Uint64); ok {
+		counter.Add(uint64(n))
+	}
+}
@@ -0,0 +1,282 @@
+package omnipulse
+
+import (
+	"log/slog"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// TailSamplingConfig configures tail-based sampling. Spans are held per trace until the
+// local root span ends, then the whole trace is kept if any policy matches.
+type TailSamplingConfig struct {
+	// Policies decide which traces are kept; tail sampling is disabled when empty
+	Policies []TailPolicy
+	// DecisionWait is how long a trace is held waiting for its local root span (default: 30s)
+	DecisionWait time.Duration
+	// MaxSpans caps the spans held across all traces; the oldest traces are decided early
+	// when it is exceeded. It also caps the number of recent decisions remembered for spans
+	// that end after their trace was decided (default: 10000)
+	MaxSpans int
+}
+
+// TailTrace is the locally collected part of a trace given to tail sampling policies
+type TailTrace struct {
+	Spans []SpanData
+	// Root is the local root span, or nil if the trace was decided before it ended
+	Root *SpanData
+}
+
+// TailPolicy reports whether a trace should be kept. Policies are called with the tail
+// sampler's lock held and should be fast.
+type TailPolicy func(t TailTrace) bool
+
+// KeepErrors keeps traces with any span whose status is SpanStatusError
+func KeepErrors() TailPolicy {
+	return func(t TailTrace) bool {
+		for _, s := range t.Spans {
+			if s.Status == SpanStatusError {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// KeepSlowerThan keeps traces whose local root took longer than d. Traces decided before
+// their root ended use their longest span.
+func KeepSlowerThan(d time.Duration) TailPolicy {
+	return func(t TailTrace) bool {
+		if t.Root != nil {
+			return time.Duration(t.Root.DurationNs) > d
+		}
+		for _, s := range t.Spans {
+			if time.Duration(s.DurationNs) > d {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// KeepAttribute keeps traces with any span whose attribute key equals value. value is
+// normalized like span attributes, so KeepAttribute("code", 500) matches an int64 500.
+func KeepAttribute(key string, value interface{}) TailPolicy {
+	value, _ = normalizeAttribute(value, -1)
+	return func(t TailTrace) bool {
+		for _, s := range t.Spans {
+			if v, ok := s.Attributes[key]; ok && reflect.DeepEqual(v, value) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// KeepProbabilistic keeps the given fraction of traces, chosen by trace ID
+func KeepProbabilistic(fraction float64) TailPolicy {
+	sampler := TraceIDRatioBased(fraction)
+	return func(t TailTrace) bool {
+		if len(t.Spans) == 0 {
+			return false
+		}
+		return sampler.ShouldSample(SamplingParameters{TraceID: t.Spans[0].TraceID}).Decision == RecordAndSample
+	}
+}
+
+// tailSampler buffers spans per trace until a keep or drop decision can be made
+type tailSampler struct {
+	config TailSamplingConfig
+	client *Client
+
+	mu     sync.Mutex
+	traces map[string]*tailTrace
+	order  []tailOrder
+	spans  int
+	// decided remembers recent decisions so spans ending after their root follow them
+	decided      map[string]tailDecision
+	decidedOrder []tailOrder
+}
+
+type tailTrace struct {
+	spans []SpanData
+	first time.Time
+}
+
+// tailOrder queues traces by arrival; entries whose trace has since been decided are skipped
+type tailOrder struct {
+	traceID string
+	first   time.Time
+}
+
+type tailDecision struct {
+	keep bool
+	at   time.Time
+}
+
+func newTailSampler(c *Client, cfg TailSamplingConfig) *tailSampler {
+	return &tailSampler{
+		config:  cfg,
+		client:  c,
+		traces:  make(map[string]*tailTrace),
+		decided: make(map[string]tailDecision),
+	}
+}
+
+// add buffers a finished span. When it is the local root, the trace is decided.
+func (t *tailSampler) add(span SpanData, localRoot bool) {
+	now := time.Now()
+
+	t.mu.Lock()
+	if d, ok := t.decided[span.TraceID]; ok {
+		t.mu.Unlock()
+		if d.keep {
+			t.client.addSpan(span)
+		}
+		return
+	}
+
+	tr := t.traces[span.TraceID]
+	if tr == nil {
+		tr = &tailTrace{first: now}
+		t.traces[span.TraceID] = tr
+		t.order = append(t.order, tailOrder{traceID: span.TraceID, first: now})
+	}
+	tr.spans = append(tr.spans, span)
+	t.spans++
+
+	var ready [][]SpanData
+	if localRoot {
+		ready = append(ready, t.takeLocked(span.TraceID, &span, now))
+	}
+	for t.spans > t.config.MaxSpans {
+		traceID, ok := t.oldestLocked()
+		if !ok {
+			break
+		}
+		t.client.diag(slog.LevelDebug, "tail sampler full, deciding trace early", "trace_id", traceID)
+		ready = append(ready, t.takeLocked(traceID, nil, now))
+	}
+	t.mu.Unlock()
+
+	t.export(ready)
+}
+
+// expire decides traces whose local root has not ended within DecisionWait
+func (t *tailSampler) expire(now time.Time) {
+	t.mu.Lock()
+	var ready [][]SpanData
+	for len(t.order) > 0 {
+		o := t.order[0]
+		if tr := t.traces[o.traceID]; tr != nil && tr.first.Equal(o.first) {
+			if now.Sub(o.first) < t.config.DecisionWait {
+				break
+			}
+			ready = append(ready, t.takeLocked(o.traceID, nil, now))
+			continue
+		}
+		t.order = t.order[1:]
+	}
+	for len(t.decidedOrder) > 0 && now.Sub(t.decidedOrder[0].first) >= t.config.DecisionWait {
+		t.forgetOldestLocked()
+	}
+	t.mu.Unlock()
+
+	t.export(ready)
+}
+
+// flush decides every buffered trace, used on shutdown
+func (t *tailSampler) flush() {
+	now := time.Now()
+	t.mu.Lock()
+	var ready [][]SpanData
+	for traceID := range t.traces {
+		ready = append(ready, t.takeLocked(traceID, nil, now))
+	}
+	t.order = nil
+	t.mu.Unlock()
+
+	t.export(ready)
+}
+
+// oldestLocked returns the oldest buffered trace. t.mu must be held.
+func (t *tailSampler) oldestLocked() (string, bool) {
+	for len(t.order) > 0 {
+		o := t.order[0]
+		if tr := t.traces[o.traceID]; tr != nil && tr.first.Equal(o.first) {
+			return o.traceID, true
+		}
+		t.order = t.order[1:]
+	}
+	return "", false
+}
+
+// takeLocked removes a trace from the buffer and applies the policies to it, returning
+// the spans to export. t.mu must be held.
+func (t *tailSampler) takeLocked(traceID string, root *SpanData, now time.Time) []SpanData {
+	tr := t.traces[traceID]
+	delete(t.traces, traceID)
+	t.spans -= len(tr.spans)
+
+	trace := TailTrace{Spans: tr.spans, Root: root}
+	keep := false
+	for _, policy := range t.config.Policies {
+		if policy(trace) {
+			keep = true
+			break
+		}
+	}
+	t.decided[traceID] = tailDecision{keep: keep, at: now}
+	t.decidedOrder = append(t.decidedOrder, tailOrder{traceID: traceID, first: now})
+	for len(t.decided) > t.config.MaxSpans {
+		t.forgetOldestLocked()
+	}
+	if !keep {
+		return nil
+	}
+	return tr.spans
+}
+
+// forgetOldestLocked drops the oldest remembered decision. t.mu must be held.
+func (t *tailSampler) forgetOldestLocked() {
+	o := t.decidedOrder[0]
+	t.decidedOrder = t.decidedOrder[1:]
+	if d, ok := t.decided[o.traceID]; ok && d.at.Equal(o.first) {
+		delete(t.decided, o.traceID)
+	}
+}
+
+// export hands kept spans to the client's buffer
+func (t *tailSampler) export(kept [][]SpanData) {
+	for _, spans := range kept {
+		for _, span := range spans {
+			t.client.addSpan(span)
+		}
+	}
+}
+
+// exportSpan passes a finished span through the tail sampler, if enabled, to the buffer
+func (c *Client) exportSpan(span SpanData, localRoot bool) {
+	if c.tail != nil {
+		c.tail.add(span, localRoot)
+		return
+	}
+	c.addSpan(span)
+}
+
+// tailWorker periodically decides traces that have waited too long for their root
+func (c *Client) tailWorker() {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(max(c.config.TailSampling.DecisionWait/4, 10*time.Millisecond))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			c.tail.expire(now)
+		case <-c.ctx.Done():
+			return
+		}
+	}
+}
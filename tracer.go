@@ -2,8 +2,7 @@ package omnipulse
 
 import (
 	"context"
-	"crypto/rand"
-	"encoding/hex"
+	"log/slog"
 	"sync"
 	"time"
 )
@@ -25,6 +24,21 @@ func SpanFromContext(ctx context.Context) *Span {
 	return span
 }
 
+// remoteSpanContextKey is the key for storing an extracted remote span context
+type remoteSpanContextKey struct{}
+
+// ContextWithRemoteSpanContext returns a new context carrying a span context extracted from
+// another process, which Tracer.Start uses as the parent when ctx has no local span
+func ContextWithRemoteSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, remoteSpanContextKey{}, sc)
+}
+
+// RemoteSpanContextFromContext retrieves the remote span context from context
+func RemoteSpanContextFromContext(ctx context.Context) SpanContext {
+	sc, _ := ctx.Value(remoteSpanContextKey{}).(SpanContext)
+	return sc
+}
+
 // SpanStatus represents the status of a span
 type SpanStatus string
 
@@ -33,12 +47,24 @@ const (
 	SpanStatusError SpanStatus = "error"
 )
 
+// SpanKind describes a span's role in a trace
+type SpanKind string
+
+const (
+	SpanKindInternal SpanKind = "internal"
+	SpanKindServer   SpanKind = "server"
+	SpanKindClient   SpanKind = "client"
+	SpanKindProducer SpanKind = "producer"
+	SpanKindConsumer SpanKind = "consumer"
+)
+
 // SpanData represents a span for sending to the backend
 type SpanData struct {
 	TraceID       string                 `json:"trace_id"`
 	SpanID        string                 `json:"span_id"`
 	ParentSpanID  string                 `json:"parent_span_id,omitempty"`
 	Name          string                 `json:"name"`
+	Kind          SpanKind               `json:"kind,omitempty"`
 	ServiceName   string                 `json:"service_name"`
 	StartTime     time.Time              `json:"start_time"`
 	EndTime       time.Time              `json:"end_time"`
@@ -47,6 +73,11 @@ type SpanData struct {
 	StatusMessage string                 `json:"status_message,omitempty"`
 	Attributes    map[string]interface{} `json:"attributes,omitempty"`
 	Events        []SpanEvent            `json:"events,omitempty"`
+	Links         []SpanLink             `json:"links,omitempty"`
+	// Dropped counts record what SpanLimits discarded
+	DroppedAttributesCount int `json:"dropped_attributes_count,omitempty"`
+	DroppedEventsCount     int `json:"dropped_events_count,omitempty"`
+	DroppedLinksCount      int `json:"dropped_links_count,omitempty"`
 }
 
 // SpanEvent represents an event within a span
@@ -54,21 +85,46 @@ type SpanEvent struct {
 	Name       string                 `json:"name"`
 	Timestamp  time.Time              `json:"timestamp"`
 	Attributes map[string]interface{} `json:"attributes,omitempty"`
+	// DroppedAttributesCount is the number of attributes discarded by SpanLimits
+	DroppedAttributesCount int `json:"dropped_attributes_count,omitempty"`
+}
+
+// SpanLink points to a span in another trace, e.g. the producer of a message consumed
+// as part of a batch
+type SpanLink struct {
+	TraceID    string                 `json:"trace_id"`
+	SpanID     string                 `json:"span_id"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+	// DroppedAttributesCount is the number of attributes discarded by SpanLimits
+	DroppedAttributesCount int `json:"dropped_attributes_count,omitempty"`
 }
 
 // Span represents an active span
 type Span struct {
-	TraceID      string
-	SpanID       string
-	ParentSpanID string
-	Name         string
-	StartTime    time.Time
-	Status       SpanStatus
-	StatusMsg    string
-	Attributes   map[string]interface{}
-	Events       []SpanEvent
-	tracer       *Tracer
-	mu           sync.Mutex
+	TraceID           string
+	SpanID            string
+	ParentSpanID      string
+	Name              string
+	Kind              SpanKind
+	StartTime         time.Time
+	Status            SpanStatus
+	StatusMsg         string
+	Attributes        map[string]interface{}
+	Events            []SpanEvent
+	Links             []SpanLink
+	sampled           bool
+	decision          SamplingDecision
+	keepOnError       bool
+	parentKeepOnError bool
+	remote            bool
+	localParent       bool
+	traceState        string
+	droppedAttributes int
+	droppedEvents     int
+	droppedLinks      int
+	ended             bool
+	tracer            *Tracer
+	mu                sync.Mutex
 }
 
 // Tracer provides distributed tracing functionality
@@ -82,23 +138,106 @@ func newTracer(c *Client) *Tracer {
 
 // StartSpan starts a new span
 func (t *Tracer) StartSpan(name string, opts ...SpanOption) *Span {
+	return t.start(context.Background(), name, opts)
+}
+
+func (t *Tracer) start(ctx context.Context, name string, opts []SpanOption) *Span {
 	span := &Span{
-		TraceID:    generateID(16),
-		SpanID:     generateID(8),
+		SpanID:     t.client.config.IDGenerator.NewSpanID(),
 		Name:       name,
+		Kind:       SpanKindInternal,
 		StartTime:  time.Now(),
 		Status:     SpanStatusOK,
 		Attributes: make(map[string]interface{}),
+		sampled:    true,
 		tracer:     t,
 	}
 
 	for _, opt := range opts {
 		opt(span)
 	}
+	// Only root spans need a new trace ID
+	if span.TraceID == "" {
+		span.TraceID = t.client.config.IDGenerator.NewTraceID()
+	}
+
+	var parent SpanContext
+	if span.ParentSpanID != "" || span.remote {
+		parent = SpanContext{
+			TraceID:    span.TraceID,
+			SpanID:     span.ParentSpanID,
+			Sampled:    span.sampled,
+			TraceState: span.traceState,
+			Remote:     span.remote,
+		}
+	}
+	result := t.client.config.Sampler.ShouldSample(SamplingParameters{
+		ParentContext:     parent,
+		ParentKeepOnError: span.parentKeepOnError,
+		TraceID:           span.TraceID,
+		Name:              name,
+		ServiceName:       t.client.config.ServiceName,
+		Attributes:        span.Attributes,
+		Links:             span.Links,
+	})
+	span.decision = result.Decision
+	span.keepOnError = result.KeepOnError && result.Decision == RecordOnly
+	span.sampled = result.Decision == RecordAndSample
+	for k, v := range result.Attributes {
+		span.setAttributeLocked(k, v)
+	}
+
+	if span.decision != Drop {
+		for _, p := range t.client.config.SpanProcessors {
+			p.OnStart(ctx, span)
+		}
+	}
 
 	return span
 }
 
+// Start starts a span as a child of the span in ctx, or of the remote span context in ctx,
+// and returns a context carrying the new span. Options such as WithParent override the
+// parent found in ctx.
+func (t *Tracer) Start(ctx context.Context, name string, opts ...SpanOption) (context.Context, *Span) {
+	if parent := SpanFromContext(ctx); parent != nil {
+		opts = append([]SpanOption{WithParent(parent)}, opts...)
+	} else if remote := RemoteSpanContextFromContext(ctx); remote.IsValid() {
+		opts = append([]SpanOption{WithRemoteParent(remote)}, opts...)
+	}
+
+	span := t.start(ctx, name, opts)
+	return ContextWithSpan(ctx, span), span
+}
+
+// Trace runs fn in a span started with Start. An error returned by fn, or a panic, is
+// recorded on the span before it ends; panics are re-raised.
+func (t *Tracer) Trace(ctx context.Context, name string, fn func(ctx context.Context) error, opts ...SpanOption) error {
+	ctx, span := t.Start(ctx, name, opts...)
+	defer func() {
+		if p := recover(); p != nil {
+			span.RecordError(&PanicError{Value: p}, WithStackTrace())
+			span.End()
+			panic(p)
+		}
+	}()
+
+	err := fn(ctx)
+	span.RecordError(err)
+	span.End()
+	return err
+}
+
+// Trace runs fn in a child span of the span in ctx, using that span's tracer. Without a
+// span in ctx there is no tracer to use, so fn runs untraced.
+func Trace(ctx context.Context, name string, fn func(ctx context.Context) error, opts ...SpanOption) error {
+	parent := SpanFromContext(ctx)
+	if parent == nil {
+		return fn(ctx)
+	}
+	return parent.tracer.Trace(ctx, name, fn, opts...)
+}
+
 // SpanOption is a function that configures a span
 type SpanOption func(*Span)
 
@@ -108,10 +247,29 @@ func WithParent(parent *Span) SpanOption {
 		if parent != nil {
 			s.TraceID = parent.TraceID
 			s.ParentSpanID = parent.SpanID
+			s.sampled = parent.sampled
+			s.parentKeepOnError = parent.keepOnError
+			s.traceState = parent.traceState
+			s.localParent = true
 		}
 	}
 }
 
+// WithRemoteParent continues the trace of a span context extracted by a Propagator
+func WithRemoteParent(sc SpanContext) SpanOption {
+	return func(s *Span) {
+		if !sc.IsValid() {
+			return
+		}
+		s.TraceID = sc.TraceID
+		s.ParentSpanID = sc.SpanID
+		s.sampled = sc.Sampled
+		s.parentKeepOnError = false
+		s.remote = sc.Remote
+		s.traceState = sc.TraceState
+	}
+}
+
 // WithTraceID sets the trace ID
 func WithTraceID(traceID string) SpanOption {
 	return func(s *Span) {
@@ -126,73 +284,192 @@ func WithParentSpanID(parentSpanID string) SpanOption {
 	}
 }
 
+// WithSpanKind sets the span kind (default: SpanKindInternal)
+func WithSpanKind(kind SpanKind) SpanOption {
+	return func(s *Span) {
+		s.Kind = kind
+	}
+}
+
+// WithLinks adds links to spans in other traces. Links are known to the sampler.
+func WithLinks(links ...SpanLink) SpanOption {
+	return func(s *Span) {
+		for _, link := range links {
+			s.addLinkLocked(link)
+		}
+	}
+}
+
 // WithAttributes sets initial attributes
 func WithAttributes(attrs map[string]interface{}) SpanOption {
 	return func(s *Span) {
 		for k, v := range attrs {
-			s.Attributes[k] = v
+			s.setAttributeLocked(k, v)
 		}
 	}
 }
 
-// SetAttribute sets an attribute on the span
+// SpanContext returns the span's propagation context
+func (s *Span) SpanContext() SpanContext {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return SpanContext{
+		TraceID:    s.TraceID,
+		SpanID:     s.SpanID,
+		Sampled:    s.sampled,
+		TraceState: s.traceState,
+	}
+}
+
+// IsRecording reports whether the span still records changes: it has not ended and was
+// not dropped by the sampler
+func (s *Span) IsRecording() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return !s.ended && s.decision != Drop
+}
+
+// endedLocked reports whether the span has ended, logging the ignored operation if so.
+// s.mu must be held.
+func (s *Span) endedLocked(op string) bool {
+	if s.ended {
+		s.tracer.client.diag(slog.LevelDebug, "ignoring "+op+" on ended span", "span", s.Name, "span_id", s.SpanID)
+	}
+	return s.ended
+}
+
+// IsSampled reports whether the span will be exported when it ends
+func (s *Span) IsSampled() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sampled
+}
+
+// SetAttribute sets an attribute on the span. Values are normalized to string, bool,
+// int64, float64 or a slice of those; other types are formatted as strings and nil is
+// dropped.
 func (s *Span) SetAttribute(key string, value interface{}) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.Attributes[key] = value
+	if s.endedLocked("SetAttribute") {
+		return
+	}
+	s.setAttributeLocked(key, value)
 }
 
 // SetStatus sets the span status
 func (s *Span) SetStatus(status SpanStatus, message string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	if s.endedLocked("SetStatus") {
+		return
+	}
 	s.Status = status
 	s.StatusMsg = message
 }
 
+// AddLink links the span to a span in another trace
+func (s *Span) AddLink(link SpanLink) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.endedLocked("AddLink") {
+		return
+	}
+	s.addLinkLocked(link)
+}
+
 // AddEvent adds an event to the span
 func (s *Span) AddEvent(name string, attrs ...map[string]interface{}) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	if s.endedLocked("AddEvent") {
+		return
+	}
 
 	var attributes map[string]interface{}
 	if len(attrs) > 0 {
 		attributes = attrs[0]
 	}
 
-	s.Events = append(s.Events, SpanEvent{
+	s.addEventLocked(SpanEvent{
 		Name:       name,
 		Timestamp:  time.Now(),
 		Attributes: attributes,
 	})
 }
 
-// End ends the span and sends it to the backend
+// EndOption configures EndWithOptions
+type EndOption func(*endConfig)
+
+type endConfig struct {
+	endTime time.Time
+}
+
+// WithEndTime sets the span's end time instead of the time End is called
+func WithEndTime(t time.Time) EndOption {
+	return func(c *endConfig) {
+		c.endTime = t
+	}
+}
+
+// End ends the span and sends it to the backend if it was sampled. Only the first call
+// has an effect; later changes to the span are ignored.
 func (s *Span) End() {
+	s.EndWithOptions()
+}
+
+// EndWithOptions ends the span like End, configured by opts
+func (s *Span) EndWithOptions(opts ...EndOption) {
+	var cfg endConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.endTime.IsZero() {
+		cfg.endTime = time.Now()
+	}
+
 	s.mu.Lock()
-	endTime := time.Now()
+	if s.endedLocked("End") {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	keptError := s.keepOnError && s.Status == SpanStatusError
+	if s.decision != RecordAndSample && !keptError {
+		s.mu.Unlock()
+		return
+	}
+
+	// Snapshot the span so the exported data never aliases it
+	attributes := make(map[string]interface{}, len(s.Attributes))
+	for k, v := range s.Attributes {
+		attributes[k] = v
+	}
+	if _, ok := attributes[samplingProbabilityKey]; ok && keptError {
+		// Error spans are kept regardless of the sampling probability
+		attributes[samplingProbabilityKey] = 1.0
+	}
 	data := SpanData{
 		TraceID:       s.TraceID,
 		SpanID:        s.SpanID,
 		ParentSpanID:  s.ParentSpanID,
 		Name:          s.Name,
+		Kind:          s.Kind,
 		ServiceName:   s.tracer.client.config.ServiceName,
 		StartTime:     s.StartTime,
-		EndTime:       endTime,
-		DurationNs:    endTime.Sub(s.StartTime).Nanoseconds(),
+		EndTime:       cfg.endTime,
+		DurationNs:    cfg.endTime.Sub(s.StartTime).Nanoseconds(),
 		Status:        s.Status,
 		StatusMessage: s.StatusMsg,
-		Attributes:    s.Attributes,
-		Events:        s.Events,
+		Attributes:    attributes,
+		Events:        append([]SpanEvent(nil), s.Events...),
+		Links:         append([]SpanLink(nil), s.Links...),
+
+		DroppedAttributesCount: s.droppedAttributes,
+		DroppedEventsCount:     s.droppedEvents,
+		DroppedLinksCount:      s.droppedLinks,
 	}
 	s.mu.Unlock()
 
-	s.tracer.client.addSpan(data)
-}
-
-// generateID generates a random hex ID
-func generateID(bytes int) string {
-	b := make([]byte, bytes)
-	_, _ = rand.Read(b)
-	return hex.EncodeToString(b)
+	s.tracer.client.processSpan(data, !s.localParent)
 }
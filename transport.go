@@ -0,0 +1,122 @@
+package omnipulse
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/masbenx/omnipulse-go/semconv"
+)
+
+// transport traces requests made through an http.Client
+type transport struct {
+	client *Client
+	base   http.RoundTripper
+}
+
+// Transport returns an http.RoundTripper for outgoing requests. Each request, including
+// every redirect hop, gets a span started with Tracer.Start(req.Context()), and its trace
+// context is injected with Config.Propagator. The span ends when the response
+// body has been read to EOF or closed, so it covers the full transfer.
+// Usage: httpClient := &http.Client{Transport: omnipulse.Transport(client, nil)}
+func Transport(client *Client, base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{client: client, base: base}
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Redirected requests carry the response that caused them
+	resends := 0
+	for r := req.Response; r != nil && r.Request != nil; r = r.Request.Response {
+		resends++
+	}
+
+	// Never record credentials embedded in the URL
+	u := *req.URL
+	u.User = nil
+
+	ctx, span := t.client.Tracer().Start(req.Context(), fmt.Sprintf("%s %s", req.Method, req.URL.Host),
+		WithAttributes(map[string]interface{}{
+			semconv.HTTPMethod:      req.Method,
+			semconv.HTTPURL:         u.String(),
+			semconv.HTTPHost:        req.URL.Host,
+			semconv.HTTPResendCount: resends,
+		}),
+		WithSpanKind(SpanKindClient),
+	)
+
+	// RoundTrippers must not modify the caller's request
+	out := req.Clone(ctx)
+	t.client.Inject(ctx, HeaderCarrier(out.Header))
+
+	start := time.Now()
+	resp, err := t.base.RoundTrip(out)
+	if err != nil {
+		span.RecordError(err)
+		span.SetAttribute(semconv.ErrorMessage, err.Error())
+		t.finish(span, req, start, 0, 0)
+		return nil, err
+	}
+
+	span.SetAttribute(semconv.HTTPStatusCode, resp.StatusCode)
+	if resp.StatusCode >= 400 {
+		span.SetStatus(SpanStatusError, fmt.Sprintf("HTTP %d", resp.StatusCode))
+		span.SetAttribute(semconv.Error, true)
+	}
+
+	// Upgraded connections must keep their io.ReadWriteCloser body, and empty bodies
+	// will never be read
+	if resp.StatusCode == http.StatusSwitchingProtocols || resp.Body == nil || resp.Body == http.NoBody {
+		t.finish(span, req, start, resp.StatusCode, 0)
+		return resp, nil
+	}
+
+	resp.Body = &tracedBody{
+		ReadCloser: resp.Body,
+		end: func(n int64) {
+			t.finish(span, req, start, resp.StatusCode, n)
+		},
+	}
+	return resp, nil
+}
+
+// finish ends the span and records the client metrics
+func (t *transport) finish(span *Span, req *http.Request, start time.Time, statusCode int, size int64) {
+	span.SetAttribute(semconv.HTTPResponseSize, size)
+	span.End()
+
+	tags := map[string]string{
+		"method":      req.Method,
+		"host":        req.URL.Host,
+		"status_code": fmt.Sprintf("%d", statusCode),
+	}
+	t.client.Metrics().RecordDuration("http.client.request.duration", time.Since(start), tags)
+	t.client.Metrics().Increment("http.client.request.count", tags)
+}
+
+// tracedBody ends a client span once the response body is exhausted or closed
+type tracedBody struct {
+	io.ReadCloser
+	n    int64
+	once sync.Once
+	end  func(n int64)
+}
+
+func (b *tracedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	if err == io.EOF {
+		b.once.Do(func() { b.end(b.n) })
+	}
+	return n, err
+}
+
+func (b *tracedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() { b.end(b.n) })
+	return err
+}